@@ -0,0 +1,195 @@
+package gelf
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+func (g *Gelf) startWorker() {
+	g.queue = make(chan []byte, g.Config.QueueSize)
+	g.done = make(chan struct{})
+	g.quit = make(chan struct{})
+	g.drained = sync.NewCond(&g.qmu)
+	g.workCtx, g.cancelWork = context.WithCancel(context.Background())
+
+	go g.work()
+}
+
+func (g *Gelf) work() {
+	defer close(g.done)
+
+	for {
+		// once quit is closed, leave the rest of the queue to stopWorker
+		select {
+		case <-g.quit:
+			return
+		default:
+		}
+
+		select {
+		case message := <-g.queue:
+			if err := g.deliver(g.workCtx, message); err != nil && g.Config.OnError == nil {
+				log.Printf("Uh oh! %s", err)
+			}
+			g.donePending()
+		case <-g.quit:
+			return
+		}
+	}
+}
+
+// enqueue hands the message to the worker. When the queue is full the
+// OverflowPolicy decides: "block" waits for room until ctx is done,
+// "drop_newest" discards the message and "drop_oldest" discards the oldest
+// queued one. With wait it blocks whatever the policy.
+func (g *Gelf) enqueue(ctx context.Context, message []byte, wait bool) error {
+	g.qmu.Lock()
+	if g.closed.Load() {
+		g.qmu.Unlock()
+		return ErrClosed
+	}
+	g.pending++
+	g.enqueuers.Add(1)
+	g.qmu.Unlock()
+	defer g.enqueuers.Done()
+
+	policy := g.Config.OverflowPolicy
+	if wait {
+		policy = "block"
+	}
+
+	switch policy {
+	case "drop_newest":
+		select {
+		case g.queue <- message:
+		default:
+			g.drop()
+		}
+	case "drop_oldest":
+		for {
+			select {
+			case g.queue <- message:
+				return nil
+			default:
+			}
+
+			select {
+			case <-g.queue:
+				g.drop()
+			default:
+			}
+		}
+	default:
+		select {
+		case g.queue <- message:
+		case <-g.quit:
+			g.drop()
+		case <-ctx.Done():
+			g.donePending()
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (g *Gelf) drop() {
+	g.stats.messagesDropped.Add(1)
+	g.donePending()
+}
+
+func (g *Gelf) donePending() {
+	g.qmu.Lock()
+	defer g.qmu.Unlock()
+
+	g.pending--
+	if g.pending == 0 {
+		g.drained.Broadcast()
+	}
+}
+
+// Flush blocks until the queue is empty and the last message has been sent,
+// then writes out any batched TCP frames and messages kept for a reconnect.
+func (g *Gelf) Flush() error {
+	if g.root != nil {
+		return g.root.Flush()
+	}
+	if g.targets != nil {
+		return g.fanout(true, (*Gelf).Flush)
+	}
+
+	if g.queue != nil {
+		g.waitPending()
+	}
+
+	g.mu.Lock()
+	err := g.flushBatch()
+	if rerr := g.flushRetry(context.Background()); err == nil {
+		err = rerr
+	}
+	g.mu.Unlock()
+
+	if err != nil {
+		g.onError(err)
+	}
+
+	return err
+}
+
+func (g *Gelf) waitPending() {
+	g.qmu.Lock()
+	defer g.qmu.Unlock()
+
+	for g.pending > 0 {
+		g.drained.Wait()
+	}
+}
+
+// stopWorkerGrace bounds the wait for the worker once the deadline of
+// stopWorker passed. A write interrupted by the deadline returns right away,
+// only writes ignoring it, e.g. of a custom Transport, take that long.
+const stopWorkerGrace = time.Second
+
+// stopWorker waits until the queue is drained or ctx is done. It must only
+// be called once, after closed is set. Messages still queued then are dropped, it returns how
+// many. It reports false if the worker was stuck in a write past the
+// deadline and left running.
+func (g *Gelf) stopWorker(ctx context.Context) (uint64, bool) {
+	if g.queue == nil {
+		return 0, true
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		g.waitPending()
+		close(drained)
+	}()
+
+	dropped := g.stats.messagesDropped.Load()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		// give up on the message in flight, then on everything queued
+		g.cancelWork()
+	}
+
+	close(g.quit)
+	select {
+	case <-g.done:
+	case <-time.After(stopWorkerGrace):
+		return g.stats.messagesDropped.Load() - dropped, false
+	}
+	g.enqueuers.Wait()
+
+	for {
+		select {
+		case <-g.queue:
+			g.drop()
+		default:
+			g.cancelWork()
+			return g.stats.messagesDropped.Load() - dropped, true
+		}
+	}
+}
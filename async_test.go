@@ -0,0 +1,340 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Async_itShouldSendQueuedMessages(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: 55578})
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	g := New(Config{
+		GraylogPort: 55578,
+		Compression: "none",
+		Async:       true,
+	})
+	defer g.Close()
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, nil, g.Log("message "+strconv.Itoa(i)))
+	}
+	assert.Equal(t, nil, g.Flush())
+	assert.Equal(t, 0, len(g.queue))
+
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 10; i++ {
+		n, err := conn.Read(buffer)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "message "+strconv.Itoa(i), g.ParseJson(string(buffer[:n]))["short_message"])
+	}
+}
+
+func Test_Async_itShouldDrainTheQueueOnClose(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55579,
+		Compression: "none",
+		Async:       true,
+	})
+
+	received := UdpServer(55579)
+	g.Log("Hello Graylog")
+	assert.Equal(t, nil, g.Close())
+
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
+}
+
+func Test_Flush_itShouldReturnImmediatelyWhenNotAsync(t *testing.T) {
+	g := New(Config{})
+
+	assert.Equal(t, nil, g.Flush())
+}
+
+// stalledGelf returns an async client whose worker is stuck sending the
+// first message, with a queue of two behind it.
+func stalledGelf(policy string) *Gelf {
+	g := New(Config{
+		GraylogPort:    55580,
+		Compression:    "none",
+		Async:          true,
+		QueueSize:      2,
+		OverflowPolicy: policy,
+	})
+
+	g.mu.Lock()
+	g.Log("message 1")
+	for len(g.queue) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	g.Log("message 2")
+	g.Log("message 3")
+
+	return g
+}
+
+func Test_Async_itShouldDropTheNewestMessageWhenFull(t *testing.T) {
+	g := stalledGelf("drop_newest")
+	g.Log("message 4")
+
+	assert.Equal(t, uint64(1), g.Stats().MessagesDropped)
+	assert.Equal(t, "message 2", g.ParseJson(string(<-g.queue))["short_message"])
+	assert.Equal(t, "message 3", g.ParseJson(string(<-g.queue))["short_message"])
+	g.mu.Unlock()
+}
+
+func Test_Async_itShouldDropTheOldestMessageWhenFull(t *testing.T) {
+	g := stalledGelf("drop_oldest")
+	g.Log("message 4")
+
+	assert.Equal(t, uint64(1), g.Stats().MessagesDropped)
+	assert.Equal(t, "message 3", g.ParseJson(string(<-g.queue))["short_message"])
+	assert.Equal(t, "message 4", g.ParseJson(string(<-g.queue))["short_message"])
+	g.mu.Unlock()
+}
+
+func Test_Async_itShouldBlockWhenFullByDefault(t *testing.T) {
+	g := stalledGelf("")
+
+	logged := make(chan bool)
+	go func() {
+		g.Log("message 4")
+		logged <- true
+	}()
+
+	select {
+	case <-logged:
+		t.Fatal("Log should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	g.mu.Unlock()
+	<-logged
+	assert.Equal(t, uint64(0), g.Stats().MessagesDropped)
+	g.Close()
+}
+
+func Test_LogContextBlocking_itShouldGiveUpAtTheDeadline(t *testing.T) {
+	g := stalledGelf("")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := g.LogContextBlocking(ctx, "message 4")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, uint64(0), g.Stats().MessagesDropped)
+	g.mu.Unlock()
+	assert.Equal(t, nil, g.Flush())
+	g.Close()
+}
+
+func Test_LogContextBlocking_itShouldWaitInsteadOfDropping(t *testing.T) {
+	g := stalledGelf("drop_newest")
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		g.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.Equal(t, nil, g.LogContextBlocking(ctx, "message 4"))
+	assert.Equal(t, uint64(0), g.Stats().MessagesDropped)
+	g.Close()
+}
+
+// slowConn is a net.Conn taking delay for every write.
+type slowConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (c slowConn) Write(b []byte) (int, error) {
+	time.Sleep(c.delay)
+	return len(b), nil
+}
+
+func (c slowConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c slowConn) Close() error                       { return nil }
+
+func slowGelf(delay time.Duration) *Gelf {
+	return New(Config{
+		Compression: "none",
+		Async:       true,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return slowConn{delay: delay}, nil
+		},
+	})
+}
+
+func Test_Shutdown_itShouldDrainTheQueue(t *testing.T) {
+	g := slowGelf(time.Millisecond)
+	for i := 0; i < 10; i++ {
+		g.Log("message " + strconv.Itoa(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	assert.Equal(t, nil, g.Shutdown(ctx))
+	assert.Equal(t, uint64(10), g.Stats().MessagesSent)
+	assert.Equal(t, ErrClosed, g.Log("Hello Graylog"))
+}
+
+func Test_Shutdown_itShouldGiveUpAtTheDeadline(t *testing.T) {
+	g := slowGelf(50 * time.Millisecond)
+	for i := 0; i < 10; i++ {
+		g.Log("message " + strconv.Itoa(i))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := g.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	stats := g.Stats()
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, errors.Is(err, context.DeadlineExceeded))
+	assert.NotEqual(t, uint64(0), stats.MessagesDropped)
+	// the message in flight at the deadline may fail instead
+	assert.Equal(t, uint64(10), stats.MessagesSent+stats.MessagesDropped+stats.SendErrors)
+	assert.Equal(t, "Shutdown dropped "+strconv.FormatUint(stats.MessagesDropped, 10)+" queued messages: context deadline exceeded", err.Error())
+	if elapsed < 100*time.Millisecond || elapsed > 500*time.Millisecond {
+		t.Errorf("Shutdown took %s, expected it to wait for the deadline", elapsed)
+	}
+}
+
+func Test_Close_itShouldNotLeakGoroutines(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	g := New(Config{
+		Compression: "none",
+		Connection:  "tcp",
+		Async:       true,
+		BatchSize:   4096,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return slowConn{}, nil
+		},
+	})
+	for i := 0; i < 10; i++ {
+		g.Log("message " + strconv.Itoa(i))
+	}
+	assert.Equal(t, nil, g.Close())
+
+	// give exiting goroutines a moment to be accounted for
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before, %d after Close", before, after)
+	}
+	assert.Equal(t, uint64(10), g.Stats().MessagesSent)
+}
+
+func Test_Close_itShouldRejectMessagesAfterwards(t *testing.T) {
+	for _, async := range []bool{false, true} {
+		g := New(Config{
+			Async: async,
+		})
+		assert.Equal(t, nil, g.Close())
+
+		assert.Equal(t, ErrClosed, g.Log("Hello Graylog"))
+		assert.Equal(t, ErrClosed, g.Send([]byte("Hello Graylog")))
+		assert.Equal(t, ErrClosed, g.Close())
+	}
+}
+
+func Test_Close_itShouldGiveUpAfterTheCloseTimeout(t *testing.T) {
+	g := slowGelf(50 * time.Millisecond)
+	g.Config.CloseTimeout = 20 * time.Millisecond
+	for i := 0; i < 10; i++ {
+		g.Log("message " + strconv.Itoa(i))
+	}
+
+	start := time.Now()
+	assert.NotEqual(t, nil, g.Close())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Close took %s, expected it to give up after the timeout", elapsed)
+	}
+}
+
+// stalledTcpGelf returns an async TCP logger whose peer accepts the
+// connection but never reads, so writes block once the socket buffers are
+// full.
+func stalledTcpGelf(t *testing.T) *Gelf {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 1)
+	t.Cleanup(func() {
+		listener.Close()
+		if conn, ok := <-accepted; ok {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		defer close(accepted)
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	g := New(Config{
+		GraylogHostname: "127.0.0.1",
+		GraylogPort:     listener.Addr().(*net.TCPAddr).Port,
+		Connection:      "tcp",
+		Async:           true,
+	})
+
+	message := strings.Repeat("Hello Graylog", 1<<18)
+	for i := 0; i < 10; i++ {
+		g.Log(message)
+	}
+
+	return g
+}
+
+func Test_Shutdown_itShouldInterruptAStalledTcpWrite(t *testing.T) {
+	g := stalledTcpGelf(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := g.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	assert.Equal(t, true, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, true, strings.HasPrefix(err.Error(), "Shutdown dropped"))
+	if elapsed > stopWorkerGrace {
+		t.Errorf("Shutdown took %s, expected it to give up at the deadline", elapsed)
+	}
+}
+
+func Test_Close_itShouldInterruptAStalledTcpWriteAfterTheCloseTimeout(t *testing.T) {
+	g := stalledTcpGelf(t)
+	g.Config.CloseTimeout = 200 * time.Millisecond
+
+	start := time.Now()
+	err := g.Close()
+	elapsed := time.Since(start)
+
+	assert.Equal(t, true, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, true, strings.HasPrefix(err.Error(), "Shutdown dropped"))
+	if elapsed > stopWorkerGrace {
+		t.Errorf("Close took %s, expected it to give up after the timeout", elapsed)
+	}
+}
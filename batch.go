@@ -0,0 +1,100 @@
+package gelf
+
+import (
+	"bufio"
+	"context"
+	"time"
+)
+
+// batchWriter hands the frames buffered by bufio.Writer to the connection,
+// after the messages kept by ReconnectBufferSize. If that fails, the frames
+// are kept too. It is only called with g.mu held.
+type batchWriter struct {
+	g *Gelf
+}
+
+func (w batchWriter) Write(p []byte) (int, error) {
+	err := w.g.flushRetry(context.Background())
+	if err == nil {
+		if err = w.g.writeRetry(context.Background(), p); err != nil {
+			w.g.stats.sendErrors.Add(1)
+		}
+	}
+	if err != nil {
+		if w.g.Config.ReconnectBufferSize > 0 {
+			w.g.keepFrames(p)
+		}
+		return 0, err
+	}
+
+	w.g.stats.bytesSent.Add(uint64(len(p)))
+	return len(p), nil
+}
+
+func (g *Gelf) startBatcher() {
+	g.batch = bufio.NewWriterSize(batchWriter{g}, g.Config.BatchSize)
+	g.batchStop = make(chan struct{})
+	g.batchDone = make(chan struct{})
+
+	go g.flushEvery(g.Config.BatchInterval)
+}
+
+// flushEvery writes out the batch periodically, so that messages do not sit
+// in the buffer for longer than BatchInterval when traffic is low.
+func (g *Gelf) flushEvery(interval time.Duration) {
+	defer close(g.batchDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.mu.Lock()
+			err := g.flushBatch()
+			g.mu.Unlock()
+
+			if err != nil {
+				g.onError(err)
+			}
+		case <-g.batchStop:
+			return
+		}
+	}
+}
+
+func (g *Gelf) stopBatcher() {
+	if g.batch == nil {
+		return
+	}
+
+	close(g.batchStop)
+	<-g.batchDone
+}
+
+// buffer adds a null-terminated frame to the batch. bufio.Writer writes the
+// batch out as soon as it is full.
+func (g *Gelf) buffer(b []byte) error {
+	_, err := g.batch.Write(b)
+	if err != nil {
+		// bufio.Writer keeps failing once a write failed, start over
+		g.batch.Reset(batchWriter{g})
+	}
+
+	return err
+}
+
+// flushBatch writes out all buffered frames. It must be called with g.mu
+// held.
+func (g *Gelf) flushBatch() error {
+	if g.batch == nil || g.batch.Buffered() == 0 {
+		return nil
+	}
+
+	err := g.batch.Flush()
+	if err != nil {
+		g.batch.Reset(batchWriter{g})
+	}
+
+	return err
+}
@@ -0,0 +1,148 @@
+package gelf
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Batch_itShouldWriteFramesInOneWrite(t *testing.T) {
+	g := New(Config{
+		Connection:    "tcp",
+		BatchSize:     4096,
+		BatchInterval: time.Hour,
+	})
+	defer g.Close()
+
+	conn := &fakeConn{}
+	g.conn = conn
+
+	g.Log("one")
+	g.Log("two")
+	g.Log("three")
+
+	g.mu.Lock()
+	assert.Equal(t, 0, len(conn.writes))
+	g.mu.Unlock()
+
+	assert.Equal(t, nil, g.Flush())
+
+	assert.Equal(t, 1, len(conn.writes))
+	frames := bytes.Split(bytes.TrimSuffix(conn.writes[0], []byte{0}), []byte{0})
+	assert.Equal(t, 3, len(frames))
+	assert.Equal(t, "three", g.ParseJson(string(frames[2]))["short_message"])
+}
+
+func Test_Batch_itShouldWriteWhenTheBufferIsFull(t *testing.T) {
+	g := New(Config{
+		Connection:    "tcp",
+		BatchSize:     16,
+		BatchInterval: time.Hour,
+	})
+	defer g.Close()
+
+	conn := &fakeConn{}
+	g.conn = conn
+
+	g.Log("Hello Graylog")
+
+	g.mu.Lock()
+	assert.NotEqual(t, 0, len(conn.writes))
+	g.mu.Unlock()
+}
+
+func Test_Batch_itShouldFlushAfterTheInterval(t *testing.T) {
+	received := TcpServer(55605)
+
+	g := New(Config{
+		GraylogPort:   55605,
+		Connection:    "tcp",
+		BatchSize:     4096,
+		BatchInterval: 10 * time.Millisecond,
+	})
+
+	g.Log("Hello Graylog")
+	time.Sleep(50 * time.Millisecond)
+
+	assert.NotEqual(t, uint64(0), g.Stats().BytesSent)
+	g.Close()
+
+	res := g.ParseJson(string(bytes.TrimSuffix(<-received, []byte{0})))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+}
+
+func Test_Batch_itShouldDrainOnClose(t *testing.T) {
+	received := TcpServer(55606)
+
+	g := New(Config{
+		GraylogPort:   55606,
+		Connection:    "tcp",
+		BatchSize:     4096,
+		BatchInterval: time.Hour,
+	})
+
+	g.Log("one")
+	g.Log("two")
+	assert.Equal(t, nil, g.Close())
+
+	frames := bytes.Split(bytes.TrimSuffix(<-received, []byte{0}), []byte{0})
+	assert.Equal(t, 2, len(frames))
+}
+
+func Test_Batch_itShouldNotAffectUDP(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55607,
+		Compression: "none",
+		BatchSize:   4096,
+	})
+	defer g.Close()
+
+	received := UdpServer(55607)
+	g.Log("Hello Graylog")
+
+	assert.Equal(t, true, g.batch == nil)
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
+}
+
+func benchmarkTCP(b *testing.B, port int, batchSize int) {
+	l, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.Copy(io.Discard, conn)
+	}()
+
+	g := New(Config{
+		GraylogPort: port,
+		Connection:  "tcp",
+		BatchSize:   batchSize,
+	})
+	defer g.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Log("Hello Graylog")
+	}
+	g.Flush()
+}
+
+func Benchmark_TCPUnbatched(b *testing.B) {
+	benchmarkTCP(b, 55608, 0)
+}
+
+func Benchmark_TCPBatched(b *testing.B) {
+	benchmarkTCP(b, 55609, 64*1024)
+}
@@ -0,0 +1,45 @@
+package gelf
+
+import "bytes"
+
+// BuildMessage returns the payload Log would send for message, before it is
+// chunked: validated, marshaled and compressed as configured. TCP payloads
+// are not compressed and lack the null byte terminating them on the wire.
+func (g *Gelf) BuildMessage(message string) ([]byte, error) {
+	return g.build(g.parse(message))
+}
+
+// BuildMessageWithFields is BuildMessage for LogWithFields.
+func (g *Gelf) BuildMessageWithFields(message string, fields map[string]interface{}) ([]byte, error) {
+	gmap, err := g.fieldsMessage(message, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.build(gmap)
+}
+
+func (g *Gelf) build(gmap map[string]interface{}) ([]byte, error) {
+	if err := g.prepare(gmap); err != nil {
+		return nil, err
+	}
+	g.addCaller(gmap)
+
+	var buf bytes.Buffer
+	if err := g.encode(&buf, gmap); err != nil {
+		return nil, err
+	}
+	msg := buf.Bytes()
+
+	switch g.network() {
+	case "tcp":
+		return msg, nil
+	case "http":
+		if g.Config.Compression != "gzip" {
+			return msg, nil
+		}
+	}
+
+	compressed := g.Compress(msg)
+	return compressed.Bytes(), nil
+}
@@ -0,0 +1,68 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_BuildMessage_itShouldReturnTheCompressedPayload(t *testing.T) {
+	g := New(Config{})
+
+	b, err := g.BuildMessage("Hello Graylog")
+	assert.Equal(t, nil, err)
+
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	assert.Equal(t, nil, err)
+	raw, err := io.ReadAll(r)
+	assert.Equal(t, nil, err)
+
+	var res map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal(raw, &res))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "1.1", res["version"])
+	assert.Equal(t, g.Config.Host, res["host"])
+	assert.Equal(t, float64(LevelInfo), res["level"])
+	assert.NotEqual(t, nil, res["timestamp"])
+}
+
+func Test_BuildMessage_itShouldKeepJsonMessages(t *testing.T) {
+	g := New(Config{Compression: "none"})
+
+	b, err := g.BuildMessage(validJson)
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "Hello From Golang! :)", res["short_message"])
+	assert.Equal(t, "Google Go", res["facility"])
+}
+
+func Test_BuildMessage_itShouldNotCompressTcpPayloads(t *testing.T) {
+	g := New(Config{Connection: "tcp"})
+
+	b, err := g.BuildMessage("Hello Graylog")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(b))["short_message"])
+}
+
+func Test_BuildMessage_itShouldValidateTheMessage(t *testing.T) {
+	g := New(Config{})
+
+	_, err := g.BuildMessage(inValidJson)
+	assert.NotEqual(t, nil, err)
+
+	_, err = g.BuildMessage(" ")
+	assert.Equal(t, ErrEmptyMessage, err)
+}
+
+func Test_BuildMessageWithFields_itShouldAddTheFields(t *testing.T) {
+	g := New(Config{Compression: "none"})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": "robert"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "robert", g.ParseJson(string(b))["_user"])
+}
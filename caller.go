@@ -0,0 +1,49 @@
+package gelf
+
+import (
+	"runtime"
+	"strings"
+)
+
+// loggerPackages are skipped looking for the call site, with their
+// subpackages: this module with its adapters, the loggers they adapt and the
+// standard library loggers, log and log/slog.
+var loggerPackages = []string{
+	"github.com/robertkowalski/graylog-golang",
+	"github.com/sirupsen/logrus",
+	"go.uber.org/zap",
+	"log",
+}
+
+// caller returns the file and line of the first frame outside of this
+// module and the loggers it adapts, i.e. the user's call site.
+func caller() (string, int, bool) {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(2, pc)
+	frames := runtime.CallersFrames(pc[:n])
+
+	for {
+		frame, more := frames.Next()
+		if !internalFrame(frame) {
+			return frame.File, frame.Line, true
+		}
+		if !more {
+			return "", 0, false
+		}
+	}
+}
+
+func internalFrame(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		// the tests of this module stand in for user code
+		return false
+	}
+
+	for _, pkg := range loggerPackages {
+		if strings.HasPrefix(frame.Function, pkg+".") || strings.HasPrefix(frame.Function, pkg+"/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package gelf
+
+import (
+	"log"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_IncludeCaller_itShouldAttachTheCallSite(t *testing.T) {
+	g := New(Config{
+		GraylogPort:   55602,
+		Compression:   "none",
+		IncludeCaller: true,
+	})
+
+	received := UdpServer(55602)
+	_, _, line, _ := runtime.Caller(0)
+	g.Info("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "caller_test.go", filepath.Base(res["_file"].(string)))
+	assert.Equal(t, float64(line+1), res["_line"])
+}
+
+func Test_IncludeCaller_itShouldSkipTheStandardLogger(t *testing.T) {
+	g := New(Config{
+		GraylogPort:   55603,
+		Compression:   "none",
+		IncludeCaller: true,
+	})
+
+	received := UdpServer(55603)
+	_, _, line, _ := runtime.Caller(0)
+	log.New(g, "", 0).Print("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "caller_test.go", filepath.Base(res["_file"].(string)))
+	assert.Equal(t, float64(line+1), res["_line"])
+}
+
+func Test_IncludeCaller_itShouldBeOffByDefault(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55604,
+		Compression: "none",
+	})
+
+	received := UdpServer(55604)
+	g.Info("Hello Graylog")
+
+	assert.Equal(t, nil, g.ParseJson(string(<-received))["_file"])
+}
@@ -0,0 +1,49 @@
+package gelf
+
+import (
+	"context"
+	"time"
+)
+
+// LogContext is like Log but gives up once ctx is done. The deadline of ctx
+// takes the place of WriteTimeout. Fields returned by Config.ContextFields
+// for ctx are added to the message.
+func (g *Gelf) LogContext(ctx context.Context, message string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return g.logContext(ctx, g.contextMessage(ctx, message))
+}
+
+// LogContextBlocking is LogContext for async loggers that must not lose
+// messages: when the queue is full it waits for room, whatever the
+// OverflowPolicy, but only until ctx is done. Then it returns ctx.Err().
+func (g *Gelf) LogContextBlocking(ctx context.Context, message string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return g.emit(ctx, g.contextMessage(ctx, message), true)
+}
+
+func (g *Gelf) contextMessage(ctx context.Context, message string) map[string]interface{} {
+	gmap := g.message(message)
+	if g.Config.ContextFields != nil {
+		for k, v := range g.Config.ContextFields(ctx) {
+			gmap[g.FieldName(k)] = v
+		}
+	}
+
+	return gmap
+}
+
+// LogWithTimeout is Log with a write deadline of timeout for this message,
+// overriding WriteTimeout. With Async it bounds the wait for room in the
+// queue instead.
+func (g *Gelf) LogWithTimeout(message string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return g.logContext(ctx, g.parse(message))
+}
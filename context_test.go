@@ -0,0 +1,112 @@
+package gelf
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_LogContext_itShouldSendTheMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55599,
+		Compression: "none",
+	})
+	defer g.Close()
+
+	received := UdpServer(55599)
+	err := g.LogContext(context.Background(), "Hello Graylog")
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
+}
+
+func Test_LogContext_itShouldReturnTheContextErrorIfCancelled(t *testing.T) {
+	g := New(Config{})
+	conn := &fakeConn{}
+	g.conn = conn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := g.LogContext(ctx, "Hello Graylog")
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(conn.writes))
+}
+
+func Test_LogContext_itShouldApplyTheDeadlineToTheWrite(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:55600")
+	assert.Equal(t, nil, err)
+	defer l.Close()
+
+	g := New(Config{
+		GraylogPort:     55600,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+	})
+	defer g.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = g.LogContext(ctx, strings.Repeat("a", 64<<20))
+
+	assert.Equal(t, true, timeout(err))
+}
+
+type requestIDKey struct{}
+
+func Test_LogContext_itShouldAddTheContextFields(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55630,
+		Compression: "none",
+		ContextFields: func(ctx context.Context) map[string]interface{} {
+			id, ok := ctx.Value(requestIDKey{}).(string)
+			if !ok {
+				return nil
+			}
+			return map[string]interface{}{"request_id": id}
+		},
+	})
+
+	received := UdpServer(55630)
+	g.LogContext(context.WithValue(context.Background(), requestIDKey{}, "abc"), "Hello Graylog")
+	assert.Equal(t, "abc", g.ParseJson(string(<-received))["_request_id"])
+
+	received = UdpServer(55630)
+	g.LogContext(context.Background(), "Hello Graylog")
+	assert.Equal(t, nil, g.ParseJson(string(<-received))["_request_id"])
+}
+
+// deadlineConn records the write deadlines set on it.
+type deadlineConn struct {
+	fakeConn
+	deadlines []time.Time
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.deadlines = append(c.deadlines, t)
+	return nil
+}
+
+func Test_LogWithTimeout_itShouldOverrideTheWriteTimeout(t *testing.T) {
+	conn := &deadlineConn{}
+	g := New(Config{
+		Compression:  "none",
+		WriteTimeout: time.Hour,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	start := time.Now()
+	assert.Equal(t, nil, g.LogWithTimeout("Hello Graylog", 10*time.Second))
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+
+	assert.Equal(t, 2, len(conn.deadlines))
+	assert.Equal(t, true, conn.deadlines[0].Sub(start) < time.Minute)
+	assert.Equal(t, true, conn.deadlines[1].Sub(start) > time.Minute)
+	assert.Equal(t, 2, len(conn.writes))
+}
@@ -0,0 +1,25 @@
+package gelf
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+// discardConn is the connection of Connection "discard", swallowing every
+// write after the message was marshaled, compressed and chunked.
+type discardConn struct{}
+
+func (discardConn) Read(b []byte) (int, error)         { return 0, io.EOF }
+func (discardConn) Write(b []byte) (int, error)        { return len(b), nil }
+func (discardConn) Close() error                       { return nil }
+func (discardConn) LocalAddr() net.Addr                { return discardAddr{} }
+func (discardConn) RemoteAddr() net.Addr               { return discardAddr{} }
+func (discardConn) SetDeadline(t time.Time) error      { return nil }
+func (discardConn) SetReadDeadline(t time.Time) error  { return nil }
+func (discardConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type discardAddr struct{}
+
+func (discardAddr) Network() string { return "discard" }
+func (discardAddr) String() string  { return "discard" }
@@ -0,0 +1,49 @@
+package gelf
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Discard_itShouldSendWithoutAListener(t *testing.T) {
+	g := New(Config{
+		Connection:      "discard",
+		Compression:     "none",
+		MaxChunkSizeWan: 100,
+	})
+
+	assert.Equal(t, nil, g.Log(strings.Repeat("Hello Graylog", 100)))
+	assert.Equal(t, nil, g.Info("Hello Graylog"))
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(2), stats.MessagesSent)
+	assert.Equal(t, true, stats.ChunksSent > 1)
+	assert.Equal(t, uint64(0), stats.SendErrors)
+}
+
+func Test_discardConn_itShouldImplementEveryMethod(t *testing.T) {
+	var conn net.Conn = discardConn{}
+
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "discard", conn.LocalAddr().String())
+	assert.Equal(t, "discard", conn.RemoteAddr().Network())
+	assert.Equal(t, nil, conn.SetDeadline(time.Now()))
+	assert.Equal(t, nil, conn.SetReadDeadline(time.Now()))
+}
+
+func Benchmark_DiscardChunked(b *testing.B) {
+	g := New(Config{Connection: "discard", Compression: "none"})
+	message := strings.Repeat("Hello World ", 1000)
+
+	b.SetBytes(int64(len(message)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Log(message)
+	}
+}
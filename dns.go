@@ -0,0 +1,88 @@
+package gelf
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+// NewWithAddr returns a Gelf sending over UDP to addr. The address is used
+// as is and never resolved, GraylogHostname and GraylogPort of config are
+// replaced with it.
+func NewWithAddr(addr *net.UDPAddr, config Config) *Gelf {
+	config.GraylogHostname = addr.IP.String()
+	config.GraylogPort = addr.Port
+
+	g := New(config)
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		return addr, nil
+	}
+	g.udpAddr = addr
+	g.resolvedAt = time.Now()
+
+	return g
+}
+
+func (g *Gelf) address() string {
+	return net.JoinHostPort(g.Config.GraylogHostname, strconv.Itoa(g.Config.GraylogPort))
+}
+
+// resolveAddr returns the cached UDP address of Graylog, resolving it again
+// once DNSRefreshInterval has passed.
+func (g *Gelf) resolveAddr() (*net.UDPAddr, error) {
+	if g.udpAddr != nil && time.Since(g.resolvedAt) < g.Config.DNSRefreshInterval {
+		return g.udpAddr, nil
+	}
+
+	udpAddr, err := g.resolve("udp", g.address())
+	if err != nil {
+		return nil, err
+	}
+	g.udpAddr = udpAddr
+	g.resolvedAt = time.Now()
+
+	return udpAddr, nil
+}
+
+// refreshAddr drops the connection when Graylog's address changed since it
+// was dialed, so the next write goes to the new address. On resolution
+// errors the old connection is kept.
+func (g *Gelf) refreshAddr() {
+	if time.Since(g.resolvedAt) < g.Config.DNSRefreshInterval {
+		return
+	}
+
+	old := g.udpAddr
+	udpAddr, err := g.resolveAddr()
+	if err != nil || old == nil {
+		return
+	}
+
+	if !udpAddr.IP.Equal(old.IP) || udpAddr.Port != old.Port || udpAddr.Zone != old.Zone {
+		g.closeConn()
+	}
+}
+
+// localAddr returns LocalAddr, an IP with or without port to send UDP from,
+// or nil to let the OS choose.
+func (g *Gelf) localAddr() (*net.UDPAddr, error) {
+	if g.Config.LocalAddr == "" {
+		return nil, nil
+	}
+
+	addr := g.Config.LocalAddr
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "0")
+	}
+
+	return net.ResolveUDPAddr("udp", addr)
+}
+
+// local reports whether Graylog resolves to a private or loopback address.
+func (g *Gelf) local() bool {
+	g.mu.Lock()
+	udpAddr, err := g.resolveAddr()
+	g.mu.Unlock()
+
+	return err == nil && (udpAddr.IP.IsPrivate() || udpAddr.IP.IsLoopback())
+}
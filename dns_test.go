@@ -0,0 +1,156 @@
+package gelf
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_resolveAddr_itShouldCacheTheAddress(t *testing.T) {
+	g := New(Config{})
+	calls := 0
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		calls++
+		return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 12201}, nil
+	}
+
+	g.resolveAddr()
+	g.resolveAddr()
+
+	assert.Equal(t, 1, calls)
+}
+
+func Test_resolveAddr_itShouldResolveAgainAfterTheRefreshInterval(t *testing.T) {
+	g := New(Config{
+		Compression:        "none",
+		DNSRefreshInterval: 10 * time.Millisecond,
+	})
+	defer g.Close()
+
+	port := 55594
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil
+	}
+
+	received := UdpServer(55594)
+	g.Send([]byte("Hello Graylog"))
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+
+	port = 55595
+	time.Sleep(20 * time.Millisecond)
+
+	received = UdpServer(55595)
+	g.Send([]byte("Hello again"))
+	assert.Equal(t, []byte("Hello again"), <-received)
+}
+
+func Test_NewWithAddr_itShouldSendToTheAddress(t *testing.T) {
+	g := NewWithAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 55639}, Config{
+		GraylogHostname: "graylog.invalid",
+		Compression:     "none",
+	})
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		return nil, errors.New("resolve called")
+	}
+
+	received := UdpServer(55639)
+	assert.Equal(t, nil, g.Send([]byte("Hello Graylog")))
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+	assert.Equal(t, "127.0.0.1", g.Config.GraylogHostname)
+	assert.Equal(t, 55639, g.Config.GraylogPort)
+}
+
+func Test_LocalAddr_itShouldSendFromTheAddress(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 55642})
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	g := New(Config{
+		GraylogHostname: "127.0.0.1",
+		GraylogPort:     55642,
+		Compression:     "none",
+		LocalAddr:       "127.0.0.1:55643",
+	})
+	defer g.Close()
+	assert.Equal(t, nil, g.Send([]byte("Hello Graylog")))
+
+	buffer := make([]byte, 1024)
+	n, from, err := conn.ReadFromUDP(buffer)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Hello Graylog", string(buffer[:n]))
+	assert.Equal(t, "127.0.0.1:55643", from.String())
+}
+
+func Test_LocalAddr_itShouldAcceptAnIPWithoutPort(t *testing.T) {
+	g := New(Config{LocalAddr: "127.0.0.1"})
+
+	addr, err := g.localAddr()
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "127.0.0.1:0", addr.String())
+
+	g = New(Config{LocalAddr: "not an address"})
+	_, err = g.localAddr()
+	assert.NotEqual(t, nil, err)
+	assert.NotEqual(t, nil, g.Log("Hello Graylog"))
+}
+
+func benchmarkSend(b *testing.B, g *Gelf) {
+	message := []byte("Hello Graylog")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Send(message)
+	}
+}
+
+func udpSink(b *testing.B) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { conn.Close() })
+
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func Benchmark_SendWithHostname(b *testing.B) {
+	addr := udpSink(b)
+	benchmarkSend(b, New(Config{GraylogHostname: "localhost", GraylogPort: addr.Port}))
+}
+
+func Benchmark_SendWithAddr(b *testing.B) {
+	benchmarkSend(b, NewWithAddr(udpSink(b), Config{}))
+}
+
+func Test_GetChunksize_itShouldDetectLocalAddressesWithAuto(t *testing.T) {
+	ips := map[string]int{
+		"10.0.0.5":    8154,
+		"192.168.1.2": 8154,
+		"127.0.0.1":   8154,
+		"::1":         8154,
+		"8.8.8.8":     1420,
+		"2001:db8::1": 1420,
+	}
+
+	for ip, size := range ips {
+		g := New(Config{Connection: "auto"})
+		g.resolve = func(network, address string) (*net.UDPAddr, error) {
+			return &net.UDPAddr{IP: net.ParseIP(ip), Port: 12201}, nil
+		}
+
+		assert.Equal(t, size, g.GetChunksize())
+	}
+}
+
+func Test_GetChunksize_itShouldFallBackToWanIfAutoCannotResolve(t *testing.T) {
+	g := New(Config{Connection: "auto"})
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		return nil, errors.New("no such host")
+	}
+
+	assert.Equal(t, 1420, g.GetChunksize())
+}
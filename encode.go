@@ -0,0 +1,83 @@
+package gelf
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// coreFields is the order of the GELF core fields with OrderedFields.
+var coreFields = []string{"version", "host", "short_message", "full_message", "timestamp", "level", "facility", "line", "file"}
+
+// encode writes gmap as JSON to buf, with Config.Marshal if set.
+// encoding/json sorts the keys of a map, with OrderedFields the core fields
+// come first and the additional fields after them, sorted.
+func (g *Gelf) encode(buf *bytes.Buffer, gmap map[string]interface{}) error {
+	if !g.Config.OrderedFields && g.Config.Marshal != nil {
+		return g.encodeValue(buf, gmap)
+	}
+	if !g.Config.OrderedFields {
+		if err := json.NewEncoder(buf).Encode(gmap); err != nil {
+			return err
+		}
+		// Encode terminates the JSON with a newline
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}
+
+	keys := make([]string, 0, len(gmap))
+	for _, k := range coreFields {
+		if _, ok := gmap[k]; ok {
+			keys = append(keys, k)
+		}
+	}
+	core := len(keys)
+	for k := range gmap {
+		if !isCoreField(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[core:])
+
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := g.encodeValue(buf, k); err != nil {
+			return err
+		}
+		buf.WriteByte(':')
+		if err := g.encodeValue(buf, gmap[k]); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+func (g *Gelf) encodeValue(buf *bytes.Buffer, v interface{}) error {
+	marshal := g.Config.Marshal
+	if marshal == nil {
+		marshal = json.Marshal
+	}
+
+	b, err := marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+
+	return nil
+}
+
+func isCoreField(k string) bool {
+	for _, core := range coreFields {
+		if k == core {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package gelf
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func orderedGelf() *Gelf {
+	return New(Config{
+		Compression:   "none",
+		Host:          "localhost",
+		OrderedFields: true,
+		Now: func() time.Time {
+			return time.Unix(1356262644, 0)
+		},
+	})
+}
+
+func Test_OrderedFields_itShouldPutTheCoreFieldsFirst(t *testing.T) {
+	g := orderedGelf()
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"user":         "robert",
+		"attempt":      3,
+		"full_message": "Hello Graylog\nwith details",
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, `{"version":"1.1","host":"localhost","short_message":"Hello Graylog","full_message":"Hello Graylog\nwith details","timestamp":1356262644,"level":6,"_attempt":3,"_user":"robert"}`, string(b))
+}
+
+func Test_OrderedFields_itShouldMarshalIdentically(t *testing.T) {
+	g := orderedGelf()
+	fields := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}
+
+	first, _ := g.BuildMessageWithFields("Hello Graylog", fields)
+	for i := 0; i < 10; i++ {
+		b, _ := g.BuildMessageWithFields("Hello Graylog", fields)
+		assert.Equal(t, string(first), string(b))
+	}
+}
+
+func Test_OrderedFields_itShouldProduceTheSameObject(t *testing.T) {
+	g := orderedGelf()
+	unordered := New(g.Config)
+	unordered.Config.OrderedFields = false
+
+	ordered, _ := g.BuildMessage(validJson)
+	plain, _ := unordered.BuildMessage(validJson)
+
+	var a, b map[string]interface{}
+	assert.Equal(t, nil, json.Unmarshal(ordered, &a))
+	assert.Equal(t, nil, json.Unmarshal(plain, &b))
+	assert.Equal(t, b, a)
+}
+
+func Test_Marshal_itShouldUseTheCustomMarshaler(t *testing.T) {
+	calls := 0
+	g := New(Config{
+		Compression: "none",
+		Marshal: func(v interface{}) ([]byte, error) {
+			calls++
+			return json.Marshal(v)
+		},
+	})
+
+	b, err := g.BuildMessage("Hello Graylog")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(b))["short_message"])
+}
+
+func Test_Marshal_itShouldReturnItsErrors(t *testing.T) {
+	fail := errors.New("unsupported value")
+	g := New(Config{
+		Connection: "discard",
+		Marshal: func(v interface{}) ([]byte, error) {
+			return nil, fail
+		},
+	})
+
+	assert.Equal(t, fail, g.Log("Hello Graylog"))
+}
+
+func benchmarkEncode(b *testing.B, config Config) {
+	g := New(config)
+	gmap, _ := g.fieldsMessage("Request handled", map[string]interface{}{
+		"request_id": "f00b4r",
+		"latency_ms": 12,
+		"status":     200,
+	})
+	g.prepare(gmap)
+	buf := getBuffer()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf.Reset()
+		g.encode(buf, gmap)
+	}
+}
+
+func Benchmark_EncodeDefault(b *testing.B) {
+	benchmarkEncode(b, Config{})
+}
+
+func Benchmark_EncodeOrdered(b *testing.B) {
+	benchmarkEncode(b, Config{OrderedFields: true})
+}
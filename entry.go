@@ -0,0 +1,76 @@
+package gelf
+
+// logEntry collects fields for a single message, see Field.
+type logEntry struct {
+	gelf   *Gelf
+	fields map[string]interface{}
+}
+
+// Field starts a message with an additional field, more can be chained:
+//
+//	g.Field("user", "robert").Field("attempt", 3).Error("Login failed")
+//
+// Keys are prefixed like with LogWithFields.
+func (g *Gelf) Field(key string, value interface{}) *logEntry {
+	e := &logEntry{
+		gelf:   g,
+		fields: make(map[string]interface{}, 4),
+	}
+
+	return e.Field(key, value)
+}
+
+func (e *logEntry) Field(key string, value interface{}) *logEntry {
+	e.fields[key] = value
+	return e
+}
+
+func (e *logEntry) Log(message string) error {
+	return e.gelf.LogWithFields(message, e.fields)
+}
+
+func (e *logEntry) Emergency(message string) error {
+	return e.logLevel(LevelEmergency, message)
+}
+
+func (e *logEntry) Alert(message string) error {
+	return e.logLevel(LevelAlert, message)
+}
+
+func (e *logEntry) Critical(message string) error {
+	return e.logLevel(LevelCritical, message)
+}
+
+func (e *logEntry) Error(message string) error {
+	return e.logLevel(LevelError, message)
+}
+
+func (e *logEntry) Warning(message string) error {
+	return e.logLevel(LevelWarning, message)
+}
+
+func (e *logEntry) Notice(message string) error {
+	return e.logLevel(LevelNotice, message)
+}
+
+func (e *logEntry) Info(message string) error {
+	return e.logLevel(LevelInfo, message)
+}
+
+func (e *logEntry) Debug(message string) error {
+	return e.logLevel(LevelDebug, message)
+}
+
+func (e *logEntry) logLevel(level int, message string) error {
+	if !e.gelf.Enabled(level) {
+		return nil
+	}
+
+	gmap, err := e.gelf.fieldsMessage(message, e.fields)
+	if err != nil {
+		return err
+	}
+	gmap["level"] = level
+
+	return e.gelf.log(gmap)
+}
@@ -0,0 +1,47 @@
+package gelf
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Field_itShouldAddTheChainedFields(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55627,
+		Compression: "none",
+	})
+
+	received := UdpServer(55627)
+	err := g.Field("user", "robert").Field("_attempt", 3).Log("Hello Graylog")
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, float64(3), res["_attempt"])
+	assert.Equal(t, float64(LevelInfo), res["level"])
+}
+
+func Test_Field_itShouldSendAtTheLevelOfTheHelper(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55628,
+		Compression: "none",
+	})
+
+	received := UdpServer(55628)
+	g.Field("user", "robert").Error("Login failed")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, float64(LevelError), res["level"])
+}
+
+func Test_Field_itShouldRespectMinLevel(t *testing.T) {
+	g := New(Config{
+		MinLevel: intPtr(LevelInfo),
+	})
+
+	assert.Equal(t, nil, g.Field("user", "robert").Debug("Hello Graylog"))
+	assert.Equal(t, uint64(0), g.Stats().MessagesSent)
+}
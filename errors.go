@@ -0,0 +1,23 @@
+package gelf
+
+import "errors"
+
+var (
+	// ErrClosed is returned for messages logged after Close or Shutdown.
+	ErrClosed = errors.New("Gelf is closed")
+
+	// ErrEmptyMessage is returned for messages with an empty or
+	// whitespace-only short_message.
+	ErrEmptyMessage = errors.New("Key short_message must not be empty")
+)
+
+// ForbiddenFieldError is returned for messages with a field Graylog would
+// reject or silently drop.
+type ForbiddenFieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ForbiddenFieldError) Error() string {
+	return "Key " + e.Field + " " + e.Reason
+}
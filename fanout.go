@@ -0,0 +1,42 @@
+package gelf
+
+import "errors"
+
+// NewFanout returns a Gelf sending every message to each endpoint in
+// configs, e.g. to two collectors for high availability. Messages are built
+// with the first Config, each endpoint compresses, chunks and queues them
+// according to its own. Logging succeeds if at least one endpoint got the
+// message, or only if all did with RequireAllTargets in the first Config.
+func NewFanout(configs ...Config) *Gelf {
+	if len(configs) == 0 {
+		return New(Config{})
+	}
+
+	front := configs[0]
+	front.Async = false
+	front.BatchSize = 0
+	g := New(front)
+
+	for _, config := range configs {
+		g.targets = append(g.targets, New(config))
+	}
+
+	return g
+}
+
+// fanout calls fn for every target. Unless all is set, it only fails if fn
+// failed for every target.
+func (g *Gelf) fanout(all bool, fn func(t *Gelf) error) error {
+	var errs []error
+	for _, t := range g.targets {
+		if err := fn(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if !all && len(errs) < len(g.targets) {
+		return nil
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_NewFanout_itShouldSendToEveryEndpoint(t *testing.T) {
+	g := NewFanout(
+		Config{GraylogPort: 55631, Compression: "none"},
+		Config{GraylogPort: 55632},
+	)
+	defer g.Close()
+
+	first := UdpServer(55631)
+	second := UdpServer(55632)
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-first))["short_message"])
+	// the second endpoint compresses with zlib
+	assert.NotEqual(t, 0, len(<-second))
+	assert.Equal(t, uint64(2), g.Stats().MessagesSent)
+}
+
+func failingConfig(conn net.Conn) Config {
+	return Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			if conn == nil {
+				return nil, errors.New("connection refused")
+			}
+			return conn, nil
+		},
+	}
+}
+
+func Test_NewFanout_itShouldSucceedIfOneEndpointDelivers(t *testing.T) {
+	conn := &fakeConn{}
+	g := NewFanout(failingConfig(nil), failingConfig(conn))
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, 1, len(conn.writes))
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+}
+
+func Test_NewFanout_itShouldFailIfNoEndpointDelivers(t *testing.T) {
+	g := NewFanout(failingConfig(nil), failingConfig(nil))
+
+	assert.NotEqual(t, nil, g.Log("Hello Graylog"))
+}
+
+func Test_NewFanout_itShouldRequireAllEndpointsIfConfigured(t *testing.T) {
+	config := failingConfig(nil)
+	config.RequireAllTargets = true
+	g := NewFanout(config, failingConfig(&fakeConn{}))
+
+	err := g.Log("Hello Graylog")
+	assert.Equal(t, "connection refused", err.Error())
+}
+
+func Test_NewFanout_itShouldCloseEveryEndpoint(t *testing.T) {
+	config := failingConfig(&fakeConn{})
+	config.Async = true
+	g := NewFanout(config, failingConfig(&fakeConn{}))
+
+	g.Log("Hello Graylog")
+	assert.Equal(t, nil, g.Close())
+
+	for _, target := range g.targets {
+		assert.Equal(t, ErrClosed, target.Log("Hello Graylog"))
+	}
+	assert.Equal(t, uint64(2), g.Stats().MessagesSent)
+}
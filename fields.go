@@ -0,0 +1,217 @@
+package gelf
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var fieldNamePattern = regexp.MustCompile(`^[\w\.\-]*$`)
+
+// LogWithFields sends message as short_message with every entry of fields
+// added as an additional field. Keys get a single leading underscore, or
+// FieldPrefix, if they lack one. full_message is sent as the GELF field of the same name, the other
+// core fields are reserved and rejected with a *ForbiddenFieldError.
+func (g *Gelf) LogWithFields(message string, fields map[string]interface{}) error {
+	gmap, err := g.fieldsMessage(message, fields)
+	if err != nil {
+		return err
+	}
+
+	return g.log(gmap)
+}
+
+var reservedFields = map[string]bool{
+	"version":       true,
+	"host":          true,
+	"short_message": true,
+	"timestamp":     true,
+	"level":         true,
+}
+
+func (g *Gelf) fieldsMessage(message string, fields map[string]interface{}) (map[string]interface{}, error) {
+	gmap := g.message(message)
+
+	for k, v := range fields {
+		if reservedFields[k] {
+			return nil, &ForbiddenFieldError{Field: k, Reason: "is reserved"}
+		}
+		if k == "full_message" {
+			gmap[k] = v
+			continue
+		}
+		gmap[g.FieldName(k)] = v
+	}
+
+	return gmap, nil
+}
+
+// FieldName returns the key k is sent as, prefixed with FieldPrefix unless
+// it already has it. A single leading underscore of k is replaced by the
+// prefix, so "_user" and "user" both become "_app_user" with the prefix
+// "_app_". Adapters building GELF objects for LogJSON use it for their keys.
+func (g *Gelf) FieldName(k string) string {
+	prefix := g.fieldPrefix()
+	if strings.HasPrefix(k, prefix) {
+		return k
+	}
+
+	return prefix + strings.TrimPrefix(k, "_")
+}
+
+// fieldPrefix returns FieldPrefix: an underscore if it is unset and
+// otherwise FieldPrefix, starting with an underscore as GELF requires for
+// additional fields unless it is empty.
+func (g *Gelf) fieldPrefix() string {
+	switch prefix := g.Config.FieldPrefix; {
+	case prefix == nil:
+		return "_"
+	case *prefix == "":
+		return ""
+	case !strings.HasPrefix(*prefix, "_"):
+		return "_" + *prefix
+	default:
+		return *prefix
+	}
+}
+
+// scalarFields replaces objects and arrays in additional fields, which
+// Graylog rejects. NestedFieldMode "flatten" turns them into one field per
+// leaf with a dotted name, e.g. _user.name, anything else into a JSON string.
+// Values are coerced first, see coerce.
+func (g *Gelf) scalarFields(gmap map[string]interface{}) error {
+	for k, v := range gmap {
+		if !isAdditionalField(k) {
+			continue
+		}
+		v = g.coerce(v)
+		gmap[k] = v
+		if scalar(v) {
+			continue
+		}
+
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 || b[0] != '{' && b[0] != '[' {
+			continue
+		}
+
+		if g.Config.NestedFieldMode != "flatten" {
+			gmap[k] = string(b)
+			continue
+		}
+
+		var nested interface{}
+		if err := json.Unmarshal(b, &nested); err != nil {
+			return err
+		}
+		delete(gmap, k)
+		g.flatten(gmap, k, nested)
+	}
+
+	return nil
+}
+
+// coerce converts values Graylog indexes inconsistently: a time.Time becomes
+// an RFC 3339 string, an error its message and a fmt.Stringer the result of
+// String, unless it is a number like a time.Duration. Booleans become "true"
+// and "false", or with BoolFieldMode "number" 1 and 0 and with "bool" they
+// stay booleans. Numbers, including a json.Number, and strings are kept.
+func (g *Gelf) coerce(v interface{}) interface{} {
+	switch c := v.(type) {
+	case bool:
+		switch g.Config.BoolFieldMode {
+		case "bool":
+			return c
+		case "number":
+			if c {
+				return 1
+			}
+			return 0
+		default:
+			return strconv.FormatBool(c)
+		}
+	case time.Time:
+		return c.Format(time.RFC3339Nano)
+	case json.Number:
+		if i, err := c.Int64(); err == nil {
+			return i
+		}
+		if f, err := c.Float64(); err == nil {
+			return f
+		}
+		return c.String()
+	case error:
+		// most errors have no exported fields and marshal to {}
+		return c.Error()
+	case fmt.Stringer:
+		// numbers stay numbers, e.g. a time.Duration or an enum with String
+		if n, ok := numeric(c); ok {
+			return n
+		}
+		return c.String()
+	}
+
+	return v
+}
+
+// numeric returns v as an int64, uint64 or float64 if its kind is numeric.
+func numeric(v interface{}) (interface{}, bool) {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return nil, false
+	}
+}
+
+func scalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, json.Number:
+		return true
+	default:
+		return false
+	}
+}
+
+func (g *Gelf) flatten(gmap map[string]interface{}, prefix string, v interface{}) {
+	switch nested := v.(type) {
+	case map[string]interface{}:
+		for k, v := range nested {
+			g.flatten(gmap, prefix+"."+k, v)
+		}
+	case []interface{}:
+		for i, v := range nested {
+			g.flatten(gmap, prefix+"."+strconv.Itoa(i), v)
+		}
+	default:
+		gmap[prefix] = g.coerce(v)
+	}
+}
+
+// isAdditionalField reports whether k is an additional field rather than a
+// GELF core field. With an empty FieldPrefix they lack the underscore.
+func isAdditionalField(k string) bool {
+	return strings.HasPrefix(k, "_") || !isCoreField(k)
+}
+
+// validateFields rejects additional fields Graylog would silently drop.
+func validateFields(gmap map[string]interface{}) error {
+	for k := range gmap {
+		if isAdditionalField(k) && !fieldNamePattern.MatchString(k) {
+			return &ForbiddenFieldError{Field: k, Reason: "contains characters not allowed in GELF field names"}
+		}
+	}
+
+	return nil
+}
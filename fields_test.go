@@ -0,0 +1,410 @@
+package gelf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_LogWithFields_itShouldAddPrefixedFields(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55571,
+		Compression: "none",
+	})
+
+	received := UdpServer(55571)
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"request_id": "abc",
+		"_latency":   12,
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "abc", res["_request_id"])
+	assert.Equal(t, float64(12), res["_latency"])
+}
+
+func Test_LogWithFields_itShouldSendTheFullMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55586,
+		Compression: "none",
+	})
+
+	received := UdpServer(55586)
+	g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"full_message": "Hello Graylog\nwith details",
+	})
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog\nwith details", res["full_message"])
+	assert.Equal(t, nil, res["_full_message"])
+}
+
+func Test_LogWithFields_itShouldRejectTheIdField(t *testing.T) {
+	g := New(Config{})
+
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"id": "23",
+	})
+
+	var forbidden *ForbiddenFieldError
+	assert.Equal(t, true, errors.As(err, &forbidden))
+	assert.Equal(t, "_id", forbidden.Field)
+	assert.Equal(t, "Key _id is forbidden", err.Error())
+}
+
+func Test_LogWithFields_itShouldRejectInvalidFieldNames(t *testing.T) {
+	g := New(Config{})
+
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"user name": "robert",
+	})
+
+	var forbidden *ForbiddenFieldError
+	assert.Equal(t, true, errors.As(err, &forbidden))
+	assert.Equal(t, "_user name", forbidden.Field)
+}
+
+func Test_TestForForbiddenValues_itShouldRejectFieldNamesWithSpaces(t *testing.T) {
+	g := New(Config{})
+	err := g.TestForForbiddenValues(map[string]interface{}{
+		"_user name": "robert",
+	})
+
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "_user name"))
+}
+
+func Test_TestForForbiddenValues_itShouldRejectFieldNamesWithSlashes(t *testing.T) {
+	g := New(Config{})
+	err := g.TestForForbiddenValues(map[string]interface{}{
+		"_path/to": "robert",
+	})
+
+	assert.NotEqual(t, nil, err)
+	assert.Equal(t, true, strings.Contains(err.Error(), "_path/to"))
+}
+
+func Test_TestForForbiddenValues_itShouldAcceptValidFieldNames(t *testing.T) {
+	g := New(Config{})
+	err := g.TestForForbiddenValues(map[string]interface{}{
+		"_request.id-2": "abc",
+	})
+
+	assert.Equal(t, nil, err)
+}
+
+func Test_LogWithFields_itShouldRejectAnEmptyShortMessage(t *testing.T) {
+	g := New(Config{})
+	err := g.LogWithFields("", map[string]interface{}{"user": "robert"})
+
+	assert.Equal(t, true, errors.Is(err, ErrEmptyMessage))
+}
+
+func Test_StaticFields_itShouldBeAddedToEveryMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort:  55610,
+		Compression:  "none",
+		StaticFields: map[string]interface{}{"service": "billing", "_env": "prod"},
+	})
+
+	received := UdpServer(55610)
+	g.Info("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "billing", res["_service"])
+	assert.Equal(t, "prod", res["_env"])
+}
+
+func Test_StaticFields_itShouldBeOverriddenByFieldsOfTheCall(t *testing.T) {
+	g := New(Config{
+		GraylogPort:  55611,
+		Compression:  "none",
+		StaticFields: map[string]interface{}{"service": "billing", "env": "prod"},
+	})
+
+	received := UdpServer(55611)
+	g.LogWithFields("Hello Graylog", map[string]interface{}{"service": "invoices"})
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "invoices", res["_service"])
+	assert.Equal(t, "prod", res["_env"])
+}
+
+func Test_StaticFields_itShouldBeValidated(t *testing.T) {
+	g := New(Config{
+		StaticFields: map[string]interface{}{"my field": "value"},
+	})
+
+	assert.NotEqual(t, nil, g.Log("Hello Graylog"))
+}
+
+func Test_LogWithFields_itShouldStringifyNestedValues(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55623,
+		Compression: "none",
+	})
+
+	received := UdpServer(55623)
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"user":  map[string]interface{}{"name": "robert", "id": 23},
+		"tags":  []string{"a", "b"},
+		"count": 3,
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, `{"id":23,"name":"robert"}`, res["_user"])
+	assert.Equal(t, `["a","b"]`, res["_tags"])
+	assert.Equal(t, float64(3), res["_count"])
+}
+
+func Test_LogWithFields_itShouldFlattenNestedValues(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55624,
+		Compression:     "none",
+		NestedFieldMode: "flatten",
+	})
+
+	received := UdpServer(55624)
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"user": map[string]interface{}{"name": "robert", "address": map[string]string{"city": "Berlin"}},
+		"tags": []string{"a", "b"},
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "robert", res["_user.name"])
+	assert.Equal(t, "Berlin", res["_user.address.city"])
+	assert.Equal(t, "a", res["_tags.0"])
+	assert.Equal(t, "b", res["_tags.1"])
+	assert.Equal(t, nil, res["_user"])
+}
+
+func Test_LogWithFields_itShouldKeepValuesMarshaledAsScalars(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55625,
+		Compression: "none",
+	})
+
+	received := UdpServer(55625)
+	g.LogWithFields("Hello Graylog", map[string]interface{}{
+		"at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+
+	assert.Equal(t, "2024-01-02T03:04:05Z", g.ParseJson(string(<-received))["_at"])
+}
+
+func Test_LogWithFields_itShouldPrefixPlainKeysOnce(t *testing.T) {
+	g := New(Config{Compression: "none"})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"user":     "robert",
+		"_service": "billing",
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, "billing", res["_service"])
+	assert.Equal(t, nil, res["__service"])
+}
+
+func Test_LogWithFields_itShouldRejectReservedKeys(t *testing.T) {
+	g := New(Config{})
+
+	for _, k := range []string{"version", "host", "short_message", "timestamp", "level"} {
+		err := g.LogWithFields("Hello Graylog", map[string]interface{}{k: "value"})
+
+		var forbidden *ForbiddenFieldError
+		assert.Equal(t, true, errors.As(err, &forbidden))
+		assert.Equal(t, k, forbidden.Field)
+		assert.Equal(t, "Key "+k+" is reserved", err.Error())
+	}
+}
+
+func Test_LogWithFields_itShouldCoerceValues(t *testing.T) {
+	g := New(Config{Compression: "none"})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"count":   3,
+		"ratio":   0.5,
+		"ok":      true,
+		"at":      time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC),
+		"ip":      net.IPv4(10, 0, 0, 1),
+		"elapsed": 1500 * time.Millisecond,
+		"nested":  map[string]interface{}{"at": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, float64(3), res["_count"])
+	assert.Equal(t, 0.5, res["_ratio"])
+	assert.Equal(t, "true", res["_ok"])
+	assert.Equal(t, "2024-01-02T03:04:05.000000006Z", res["_at"])
+	assert.Equal(t, "10.0.0.1", res["_ip"])
+	assert.Equal(t, float64(1500*time.Millisecond), res["_elapsed"])
+	assert.Equal(t, `{"at":"2024-01-02T03:04:05Z"}`, res["_nested"])
+}
+
+type testLevel int
+
+func (l testLevel) String() string { return "level " + strconv.Itoa(int(l)) }
+
+func Test_LogWithFields_itShouldKeepNumericStringersNumbers(t *testing.T) {
+	g := New(Config{Compression: "none"})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"n":        json.Number("42"),
+		"ratio":    json.Number("0.5"),
+		"severity": testLevel(3),
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, float64(42), res["_n"])
+	assert.Equal(t, 0.5, res["_ratio"])
+	assert.Equal(t, float64(3), res["_severity"])
+}
+
+func Test_LogWithFields_itShouldSendErrorMessages(t *testing.T) {
+	g := New(Config{
+		Compression:  "none",
+		StaticFields: map[string]interface{}{"_init_err": errors.New("config missing")},
+	})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"err": fmt.Errorf("request failed: %w", errors.New("connection refused")),
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "request failed: connection refused", res["_err"])
+	assert.Equal(t, "config missing", res["_init_err"])
+}
+
+func Test_LogWithFields_itShouldCoerceBooleans(t *testing.T) {
+	fields := map[string]interface{}{
+		"ok":     true,
+		"failed": false,
+		"user":   map[string]interface{}{"admin": true},
+	}
+
+	g := New(Config{Compression: "none", NestedFieldMode: "flatten"})
+	b, _ := g.BuildMessageWithFields("Hello Graylog", fields)
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "true", res["_ok"])
+	assert.Equal(t, "false", res["_failed"])
+	assert.Equal(t, "true", res["_user.admin"])
+
+	g = New(Config{Compression: "none", BoolFieldMode: "number"})
+	b, _ = g.BuildMessageWithFields("Hello Graylog", fields)
+	res = g.ParseJson(string(b))
+	assert.Equal(t, float64(1), res["_ok"])
+	assert.Equal(t, float64(0), res["_failed"])
+
+	g = New(Config{Compression: "none", BoolFieldMode: "bool"})
+	b, _ = g.BuildMessageWithFields("Hello Graylog", fields)
+	res = g.ParseJson(string(b))
+	assert.Equal(t, true, res["_ok"])
+	assert.Equal(t, false, res["_failed"])
+}
+
+func Test_SkipValidation_itShouldSendInvalidFieldNames(t *testing.T) {
+	g := New(Config{Compression: "none", SkipValidation: true})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user name": "robert"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "robert", g.ParseJson(string(b))["_user name"])
+	assert.Equal(t, ErrEmptyMessage, g.Log(""))
+}
+
+func benchmarkValidation(b *testing.B, skip bool) {
+	g := New(Config{Connection: "discard", Compression: "none", SkipValidation: skip})
+	fields := map[string]interface{}{
+		"request_id": "f00b4r",
+		"user":       "robert",
+		"latency_ms": 12,
+		"status":     200,
+		"path":       "/api/v1/invoices",
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.LogWithFields("Request handled", fields)
+	}
+}
+
+func Benchmark_LogWithFieldsValidated(b *testing.B) {
+	benchmarkValidation(b, false)
+}
+
+func Benchmark_LogWithFieldsSkipValidation(b *testing.B) {
+	benchmarkValidation(b, true)
+}
+
+func Test_FieldPrefix_itShouldPrefixTheKeys(t *testing.T) {
+	g := New(Config{
+		Compression:  "none",
+		FieldPrefix:  strPtr("_app_"),
+		StaticFields: map[string]interface{}{"env": "prod"},
+	})
+
+	b, _ := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"user":     "robert",
+		"_service": "billing",
+		"_app_id":  23,
+	})
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "robert", res["_app_user"])
+	assert.Equal(t, "billing", res["_app_service"])
+	assert.Equal(t, float64(23), res["_app_id"])
+	assert.Equal(t, "prod", res["_app_env"])
+}
+
+func Test_FieldPrefix_itShouldStartWithAnUnderscore(t *testing.T) {
+	g := New(Config{Compression: "none", FieldPrefix: strPtr("app.")})
+
+	b, _ := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": "robert"})
+	assert.Equal(t, "robert", g.ParseJson(string(b))["_app.user"])
+}
+
+func Test_FieldPrefix_itShouldAllowAnEmptyPrefix(t *testing.T) {
+	g := New(Config{Compression: "none", FieldPrefix: strPtr("")})
+
+	b, _ := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": "robert"})
+	assert.Equal(t, "robert", g.ParseJson(string(b))["user"])
+}
+
+func Test_FieldPrefix_itShouldValidateUnprefixedFields(t *testing.T) {
+	g := New(Config{Compression: "none", FieldPrefix: strPtr("")})
+
+	_, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"bad key/x": map[string]interface{}{"a": 1}})
+	assert.Equal(t, &ForbiddenFieldError{Field: "bad key/x", Reason: "contains characters not allowed in GELF field names"}, err)
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": map[string]interface{}{"name": "robert"}})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, `{"name":"robert"}`, g.ParseJson(string(b))["user"])
+
+	g.Config.NestedFieldMode = "flatten"
+	b, _ = g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": map[string]interface{}{"name": "robert"}})
+	assert.Equal(t, "robert", g.ParseJson(string(b))["user.name"])
+
+	g.Config.Strict = true
+	_, err = g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{"user": map[string]interface{}{"name": "robert"}})
+	assert.Equal(t, &ForbiddenFieldError{Field: "user", Reason: "is not a string, number or boolean"}, err)
+}
+
+func strPtr(s string) *string {
+	return &s
+}
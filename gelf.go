@@ -1,16 +1,26 @@
 package gelf
 
 import (
+	"bufio"
 	"bytes"
 	"compress/zlib"
+	"context"
 	"crypto/rand"
+	"crypto/tls"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
-	"math"
 	"net"
-	"strconv"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 const (
@@ -19,18 +29,124 @@ const (
 	defaultConnection      = "wan"
 	defaultMaxChunkSizeWan = 1420
 	defaultMaxChunkSizeLan = 8154
+	defaultCompression     = "zlib"
+	defaultLevel           = LevelInfo
+	defaultHost            = "localhost"
+	defaultGelfVersion     = "1.1"
+	defaultQueueSize       = 1000
+	defaultOverflowPolicy  = "block"
+	defaultRetryBackoff    = 50 * time.Millisecond
+	defaultDNSRefresh      = 5 * time.Minute
+	defaultBatchInterval   = 100 * time.Millisecond
+	defaultHTTPTimeout     = 30 * time.Second
+	defaultReconnectRetry  = time.Second
+	defaultTCPKeepAlive    = 30 * time.Second
+	defaultNestedFieldMode = "stringify"
+	defaultBoolFieldMode   = "string"
+	maxChunkCount          = 128
+	chunkHeaderSize        = 12
+	datagramOverhead       = 40 + 8 // IPv6 and UDP headers
 )
 
 type Config struct {
-	GraylogPort     int
-	GraylogHostname string
-	Connection      string
-	MaxChunkSizeWan int
-	MaxChunkSizeLan int
+	GraylogPort          int
+	GraylogHostname      string
+	Connection           string
+	MaxChunkSizeWan      int
+	MaxChunkSizeLan      int
+	Compression          string
+	CompressionLevel     int // 0 means the default, not zlib.NoCompression
+	TLS                  *tls.Config
+	UseTLS               bool
+	Host                 string
+	Async                bool
+	QueueSize            int
+	OverflowPolicy       string
+	WriteTimeout         time.Duration
+	MaxRetries           int
+	RetryBackoff         time.Duration
+	OnError              func(error)
+	DNSRefreshInterval   time.Duration
+	UnixSocketPath       string
+	PathMTU              int
+	GelfVersion          string
+	Dialer               func(ctx context.Context) (net.Conn, error)
+	IncludeCaller        bool
+	BatchSize            int
+	BatchInterval        time.Duration
+	SampleRate           float64
+	SampleKey            func(message string) string
+	StaticFields         map[string]interface{}
+	MinLevel             *int
+	HTTPClient           *http.Client
+	Facility             string
+	Now                  func() time.Time
+	UDPSendBufferBytes   int
+	NestedFieldMode      string
+	NoDefaults           bool
+	CloseTimeout         time.Duration
+	ChunkSendDelay       time.Duration
+	IncludePID           bool
+	ContextFields        func(ctx context.Context) map[string]interface{}
+	RequireAllTargets    bool
+	OrderedFields        bool
+	MaxMessageBytes      int
+	LoggerName           string
+	ReconnectBufferSize  int
+	ReconnectInterval    time.Duration
+	TCPKeepAlive         time.Duration
+	Transport            Transport
+	BoolFieldMode        string
+	SkipValidation       bool
+	Marshal              func(v interface{}) ([]byte, error)
+	CompressionThreshold int
+	FieldPrefix          *string
+	Source               string
+	LocalAddr            string
+	IncludeSequence      bool
+	Strict               bool
+	Tee                  io.Writer
 }
 
+// Gelf sends messages to Graylog. It is safe for concurrent use.
 type Gelf struct {
 	Config
+
+	mu   sync.Mutex
+	conn net.Conn
+
+	queue      chan []byte
+	done       chan struct{}
+	quit       chan struct{}
+	workCtx    context.Context
+	cancelWork context.CancelFunc
+	qmu        sync.Mutex
+	drained    *sync.Cond
+	pending    int
+	enqueuers  sync.WaitGroup
+	closed     atomic.Bool
+
+	stats counters
+
+	resolve    func(network, address string) (*net.UDPAddr, error)
+	keepAlive  func(conn net.Conn, period time.Duration) error
+	udpAddr    *net.UDPAddr
+	resolvedAt time.Time
+
+	batch     *bufio.Writer
+	batchStop chan struct{}
+	batchDone chan struct{}
+
+	retry     [][]byte
+	retryStop chan struct{}
+	retryDone chan struct{}
+
+	teeMu sync.Mutex
+
+	root     *Gelf
+	pid      int
+	sequence atomic.Uint64
+	targets  []*Gelf
 }
 
 func New(config Config) *Gelf {
@@ -41,82 +157,504 @@ func New(config Config) *Gelf {
 	if config.GraylogHostname == "" {
 		config.GraylogHostname = defaultGraylogHostname
 	}
+	config.Connection = strings.ToLower(strings.TrimSpace(config.Connection))
 	if config.Connection == "" {
 		config.Connection = defaultConnection
 	}
-	if config.MaxChunkSizeWan == 0 {
-		config.MaxChunkSizeWan = defaultMaxChunkSizeWan
+	if config.MaxChunkSizeWan <= 0 {
+		config.MaxChunkSizeWan = chunkSizeForMTU(config.PathMTU, defaultMaxChunkSizeWan)
+	}
+	if config.MaxChunkSizeLan <= 0 {
+		config.MaxChunkSizeLan = chunkSizeForMTU(config.PathMTU, defaultMaxChunkSizeLan)
+	}
+	if config.Compression == "" {
+		config.Compression = defaultCompression
+	}
+	if config.CompressionLevel == 0 || config.CompressionLevel < zlib.HuffmanOnly || config.CompressionLevel > zlib.BestCompression {
+		config.CompressionLevel = zlib.DefaultCompression
+	}
+	if config.Host == "" {
+		config.Host = hostname()
+	}
+	if config.GelfVersion == "" {
+		config.GelfVersion = defaultGelfVersion
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = defaultQueueSize
+	}
+	if config.OverflowPolicy == "" {
+		config.OverflowPolicy = defaultOverflowPolicy
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+	if config.DNSRefreshInterval <= 0 {
+		config.DNSRefreshInterval = defaultDNSRefresh
+	}
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = 1
+	}
+	if config.MinLevel != nil && (*config.MinLevel < LevelEmergency || *config.MinLevel > LevelDebug) {
+		config.MinLevel = nil
+	}
+	if config.NestedFieldMode == "" {
+		config.NestedFieldMode = defaultNestedFieldMode
 	}
-	if config.MaxChunkSizeLan == 0 {
-		config.MaxChunkSizeLan = defaultMaxChunkSizeLan
+	if config.BoolFieldMode == "" {
+		config.BoolFieldMode = defaultBoolFieldMode
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	if config.BatchInterval <= 0 {
+		config.BatchInterval = defaultBatchInterval
+	}
+	if config.ReconnectInterval <= 0 {
+		config.ReconnectInterval = defaultReconnectRetry
+	}
+	if config.TCPKeepAlive == 0 {
+		config.TCPKeepAlive = defaultTCPKeepAlive
 	}
 
 	g := &Gelf{
-		Config: config,
+		Config:    config,
+		resolve:   net.ResolveUDPAddr,
+		keepAlive: setKeepAlive,
+		pid:       os.Getpid(),
+	}
+
+	if config.StaticFields != nil {
+		static := make(map[string]interface{}, len(config.StaticFields))
+		for k, v := range config.StaticFields {
+			static[g.FieldName(k)] = v
+		}
+		g.Config.StaticFields = static
+	}
+
+	if config.BatchSize > 0 && g.network() == "tcp" {
+		g.startBatcher()
+	}
+	if config.ReconnectBufferSize > 0 && g.network() == "tcp" {
+		g.startRetrier()
+	}
+	if config.Async {
+		g.startWorker()
 	}
 
 	return g
 }
 
-func (g *Gelf) Log(message string) {
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return defaultHost
+	}
+
+	return host
+}
+
+func (g *Gelf) Log(message string) error {
+	return g.log(g.parse(message))
+}
+
+// LogJSON sends a prebuilt GELF object. Missing version and host fields are
+// filled in unless NoDefaults is set, a missing short_message is an error.
+// obj is not modified.
+func (g *Gelf) LogJSON(obj map[string]interface{}) error {
+	if g.validates() {
+		if err := g.TestForForbiddenValues(obj); err != nil {
+			return err
+		}
+	}
+
+	return g.log(g.jsonMessage(obj))
+}
+
+// LogReader sends the GELF object read from r, e.g. a pipe another component
+// writes to. It is decoded straight from r and handled like LogJSON. Read
+// and JSON syntax errors are returned.
+func (g *Gelf) LogReader(r io.Reader) error {
+	var obj map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&obj); err != nil {
+		return err
+	}
+
+	return g.LogJSON(obj)
+}
+
+// parse returns the GELF object for a message passed to Log: a JSON object
+// is used as is, anything else becomes the short_message.
+func (g *Gelf) parse(message string) map[string]interface{} {
 	msgJson := g.ParseJson(message)
+	if msgJson == nil {
+		return g.message(message)
+	}
 
-	err := g.TestForForbiddenValues(msgJson)
-	if err != nil {
-		log.Printf("Uh oh! %s", err)
+	return g.jsonMessage(msgJson)
+}
+
+func (g *Gelf) jsonMessage(obj map[string]interface{}) map[string]interface{} {
+	gmap := make(map[string]interface{}, len(obj)+4)
+	for k, v := range obj {
+		gmap[k] = v
+	}
+	if g.Config.NoDefaults {
+		return gmap
+	}
+	if _, ok := gmap["version"]; !ok {
+		gmap["version"] = g.Config.GelfVersion
+	}
+	if _, ok := gmap["host"]; !ok {
+		gmap["host"] = g.Config.Host
+	}
+
+	return gmap
+}
+
+func (g *Gelf) Write(p []byte) (int, error) {
+	if err := g.log(g.message(strings.TrimSuffix(string(p), "\n"))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// NewStdLogger returns a standard library logger writing to g. Prefix and
+// flags end up in the short_message.
+func NewStdLogger(g *Gelf, prefix string, flag int) *log.Logger {
+	return log.New(g, prefix, flag)
+}
+
+// LogFull sends a short summary along with the full message, e.g. a
+// stack trace.
+func (g *Gelf) LogFull(short, full string) error {
+	gmap := g.message(short)
+	gmap["full_message"] = full
+
+	return g.log(gmap)
+}
+
+func (g *Gelf) log(gmap map[string]interface{}) error {
+	return g.logContext(context.Background(), gmap)
+}
+
+func (g *Gelf) logContext(ctx context.Context, gmap map[string]interface{}) error {
+	return g.emit(ctx, gmap, false)
+}
+
+// emit prepares, encodes and sends gmap. With wait a full async queue is
+// waited for regardless of OverflowPolicy.
+func (g *Gelf) emit(ctx context.Context, gmap map[string]interface{}, wait bool) error {
+	if err := g.prepare(gmap); err != nil {
+		return err
+	}
+
+	if level, ok := intLevel(gmap["level"]); ok && !g.Enabled(level) {
+		return nil
+	}
+	if !g.sample(gmap) {
+		g.base().stats.messagesSampledOut.Add(1)
+		return nil
+	}
+	g.addCaller(gmap)
+	if g.Config.IncludeSequence {
+		// numbered after sampling, so gaps only mean lost messages
+		gmap["_sequence_number"] = g.base().sequence.Add(1)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if err := g.encode(buf, gmap); err != nil {
+		return err
+	}
+
+	return g.base().send(ctx, buf.Bytes(), wait)
+}
+
+// prepare adds the configured and default fields to gmap and validates it.
+func (g *Gelf) prepare(gmap map[string]interface{}) error {
+	for k, v := range g.Config.StaticFields {
+		if _, ok := gmap[k]; !ok {
+			gmap[k] = v
+		}
+	}
+	if g.Config.Facility != "" {
+		g.addField(gmap, "facility", g.Config.Facility)
+	}
+	if g.Config.Source != "" {
+		g.addField(gmap, "source", g.Config.Source)
+	}
+	if _, ok := gmap["_pid"]; !ok && g.Config.IncludePID {
+		gmap["_pid"] = g.base().pid
+	}
+	if _, ok := gmap["_logger"]; !ok && g.Config.LoggerName != "" {
+		gmap["_logger"] = g.Config.LoggerName
+	}
+	if g.Config.Strict {
+		if err := g.strictFields(gmap); err != nil {
+			return err
+		}
+	} else {
+		normalize(gmap)
+	}
+	if err := g.scalarFields(gmap); err != nil {
+		return err
+	}
+
+	if g.validates() {
+		if err := g.TestForForbiddenValues(gmap); err != nil {
+			return err
+		}
+	}
+	if err := g.testForRequiredValues(gmap); err != nil {
+		return err
+	}
+	if g.Config.MaxMessageBytes > 0 {
+		g.truncate(gmap)
+	}
+	if g.Config.NoDefaults {
+		return nil
+	}
+
+	if _, ok := gmap["level"]; !ok {
+		gmap["level"] = defaultLevel
+	}
+	if _, ok := gmap["timestamp"]; !ok {
+		gmap["timestamp"] = float64(g.Config.Now().UnixNano()) / float64(time.Second)
+	}
+
+	return nil
+}
+
+func (g *Gelf) addCaller(gmap map[string]interface{}) {
+	if _, ok := gmap["_file"]; ok || !g.Config.IncludeCaller {
 		return
 	}
 
-	compressed := g.Compress([]byte(message))
+	if file, line, ok := caller(); ok {
+		gmap["_file"] = file
+		gmap["_line"] = line
+	}
+}
+
+// addField sets a field GELF 1.0 had in the core, like facility, unless the
+// message has it. For GELF 1.1 it is an additional field.
+func (g *Gelf) addField(gmap map[string]interface{}, k string, v string) {
+	if _, ok := gmap[k]; ok {
+		return
+	}
+	if _, ok := gmap["_"+k]; ok {
+		return
+	}
+
+	if g.Config.GelfVersion == "1.0" {
+		gmap[k] = v
+	} else {
+		gmap["_"+k] = v
+	}
+}
 
-	chunksize := g.Config.MaxChunkSizeWan
+func (g *Gelf) send(ctx context.Context, message []byte, wait bool) error {
+	if g.closed.Load() {
+		return ErrClosed
+	}
+	if g.targets != nil {
+		return g.fanout(g.Config.RequireAllTargets, func(t *Gelf) error {
+			return t.send(ctx, message, wait)
+		})
+	}
+	if g.queue != nil {
+		// message lives in a pooled buffer, the worker needs its own copy
+		return g.enqueue(ctx, append([]byte(nil), message...), wait)
+	}
+
+	return g.deliver(ctx, message)
+}
+
+func (g *Gelf) deliver(ctx context.Context, message []byte) error {
+	err := g.transmit(ctx, message)
+	if err != nil {
+		g.onError(err)
+		return err
+	}
+
+	g.stats.messagesSent.Add(1)
+	return nil
+}
+
+func (g *Gelf) transmit(ctx context.Context, message []byte) error {
+	switch g.network() {
+	case "tcp":
+		g.tee(message)
+		return g.sendLocked(ctx, message)
+	case "http":
+		return g.post(ctx, message)
+	case "transport":
+		return g.transport(ctx, message)
+	}
+
+	compressed := getBuffer()
+	defer putBuffer(compressed)
+	g.compress(compressed, message)
+	g.tee(compressed.Bytes())
+
+	chunksize := g.GetChunksize()
 	length := compressed.Len()
 
-	if length > chunksize {
+	// a message that fits in one datagram is sent as is, without chunk header
+	if length <= chunksize {
+		return g.sendLocked(ctx, compressed.Bytes())
+	}
 
-		chunkCountInt := int(math.Ceil(float64(length) / float64(chunksize)))
+	chunks := Chunks(compressed.Bytes(), chunksize, generateMessageID())
+	if chunks == nil {
+		return fmt.Errorf("Message needs %d chunks of %d bytes, GELF allows at most %d", (length+chunksize-1)/chunksize, chunksize, maxChunkCount)
+	}
 
-		id := make([]byte, 8)
-		rand.Read(id)
+	// keep the chunks of one message together on the wire
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
-		for i, index := 0, 0; i < length; i, index = i+chunksize, index+1 {
-			packet := g.CreateChunkedMessage(index, chunkCountInt, id, &compressed)
-			g.Send(packet.Bytes())
+	for index, chunk := range chunks {
+		if index > 0 && g.Config.ChunkSendDelay > 0 {
+			// pace the chunks so they don't overrun the receiver's buffer
+			time.Sleep(g.Config.ChunkSendDelay)
+		}
+		if err := g.sendPacket(ctx, chunk); err != nil {
+			return err
 		}
+		g.stats.chunksSent.Add(1)
+	}
 
-	} else {
-		g.Send(compressed.Bytes())
+	return nil
+}
+
+func (g *Gelf) message(shortMessage string) map[string]interface{} {
+	return map[string]interface{}{
+		"version":       g.Config.GelfVersion,
+		"host":          g.Config.Host,
+		"short_message": shortMessage,
 	}
 }
 
+// generateMessageID returns the random 8 byte ID shared by all chunks of
+// one message.
+func generateMessageID() []byte {
+	id := make([]byte, 8)
+	rand.Read(id)
+
+	return id
+}
+
 func (g *Gelf) CreateChunkedMessage(index int, chunkCountInt int, id []byte, compressed *bytes.Buffer) bytes.Buffer {
 	var packet bytes.Buffer
+	g.writeChunk(&packet, g.GetChunksize(), index, chunkCountInt, id, compressed)
 
-	chunksize := g.GetChunksize()
+	return packet
+}
 
-	packet.Write(g.IntToBytes(30))
-	packet.Write(g.IntToBytes(15))
+// writeChunk writes the chunk header and the next chunksize bytes of
+// compressed to packet.
+func (g *Gelf) writeChunk(packet *bytes.Buffer, chunksize int, index int, chunkCountInt int, id []byte, compressed *bytes.Buffer) {
+	packet.Write([]byte{0x1e, 0x0f})
 	packet.Write(id)
 
-	packet.Write(g.IntToBytes(index))
-	packet.Write(g.IntToBytes(chunkCountInt))
+	packet.WriteByte(byte(index))
+	packet.WriteByte(byte(chunkCountInt))
 
 	packet.Write(compressed.Next(chunksize))
+}
 
-	return packet
+// Chunks splits payload into the datagrams of a chunked GELF message, e.g.
+// to send them yourself. Each starts with the chunk header carrying id, which
+// must be 8 bytes, followed by at most chunkSize bytes of payload. A payload
+// that fits into one chunk is returned as the only datagram, without header,
+// the way Log sends it. It returns nil if the payload needs more than the 128
+// chunks GELF allows.
+func Chunks(payload []byte, chunkSize int, id []byte) [][]byte {
+	chunkSize = positiveOr(chunkSize, 1)
+	if len(payload) <= chunkSize {
+		return [][]byte{payload}
+	}
+
+	// rounded up, so the last chunk holds 1 to chunkSize bytes, never 0
+	count := (len(payload) + chunkSize - 1) / chunkSize
+	if count > maxChunkCount {
+		return nil
+	}
+
+	// all chunks share one allocation
+	buf := make([]byte, 0, len(payload)+count*(4+len(id)))
+	chunks := make([][]byte, 0, count)
+	for index := 0; index < count; index++ {
+		start := len(buf)
+		buf = append(buf, 0x1e, 0x0f)
+		buf = append(buf, id...)
+		buf = append(buf, byte(index), byte(count))
+		buf = append(buf, payload[index*chunkSize:min((index+1)*chunkSize, len(payload))]...)
+		chunks = append(chunks, buf[start:len(buf):len(buf)])
+	}
+
+	return chunks
 }
 
+// IsChunked reports whether datagram is a GELF chunk: it starts with the
+// magic bytes 0x1e 0x0f and is long enough for the chunk header.
+func IsChunked(datagram []byte) bool {
+	return len(datagram) >= chunkHeaderSize && datagram[0] == 0x1e && datagram[1] == 0x0f
+}
+
+// ChunkHeader parses the header of a GELF chunk: the magic bytes, the 8 byte
+// message ID, the sequence number and the total number of chunks. ok is
+// false if datagram is not a chunk.
+func ChunkHeader(datagram []byte) (id []byte, seq, total byte, ok bool) {
+	if !IsChunked(datagram) {
+		return nil, 0, 0, false
+	}
+
+	return datagram[2:10], datagram[10], datagram[11], true
+}
+
+// chunkSizeForMTU returns how many bytes of payload fit in one chunk without
+// fragmenting on a path with the given MTU. It returns def if mtu is unset
+// or too small.
+func chunkSizeForMTU(mtu int, def int) int {
+	size := mtu - datagramOverhead - chunkHeaderSize
+	if mtu <= 0 || size < 1 {
+		return def
+	}
+
+	return size
+}
+
+// GetChunksize returns the chunk size for Connection. "auto" picks the LAN
+// size for private and loopback addresses. Any other value than "lan" gets
+// the smaller, safer WAN size. The size excludes the chunk header and is at
+// least 1, even if the Config was changed after New.
 func (g *Gelf) GetChunksize() int {
 
-	if g.Config.Connection == "wan" {
-		return g.Config.MaxChunkSizeWan
+	if g.Config.Connection == "lan" || g.Config.Connection == "auto" && g.local() {
+		return positiveOr(g.Config.MaxChunkSizeLan, defaultMaxChunkSizeLan)
 	}
 
-	if g.Config.Connection == "lan" {
-		return g.Config.MaxChunkSizeLan
+	return positiveOr(g.Config.MaxChunkSizeWan, defaultMaxChunkSizeWan)
+}
+
+// EffectiveChunkDataSize returns how many bytes of the compressed message
+// fit in one chunk. MaxChunkSizeWan and MaxChunkSizeLan already exclude the
+// 12 byte chunk header, so this is GetChunksize; a chunk on the wire is
+// header plus this many bytes.
+func (g *Gelf) EffectiveChunkDataSize() int {
+	return g.GetChunksize()
+}
+
+func positiveOr(size int, def int) int {
+	if size < 1 {
+		return def
 	}
 
-	return g.Config.MaxChunkSizeWan
+	return size
 }
 
 func (g *Gelf) IntToBytes(i int) []byte {
@@ -131,12 +669,29 @@ func (g *Gelf) IntToBytes(i int) []byte {
 
 func (g *Gelf) Compress(b []byte) bytes.Buffer {
 	var buf bytes.Buffer
-	comp := zlib.NewWriter(&buf)
+	g.compress(&buf, b)
+
+	return buf
+}
+
+func (g *Gelf) compress(buf *bytes.Buffer, b []byte) {
+	if !g.compresses(b) {
+		buf.Write(b)
+		return
+	}
 
+	gzipped := g.Config.Compression == "gzip"
+	comp := getCompressor(gzipped, g.Config.CompressionLevel, buf)
 	comp.Write(b)
 	comp.Close()
+	putCompressor(gzipped, g.Config.CompressionLevel, comp)
+}
 
-	return buf
+// compresses reports whether b is compressed: Compression is not "none" and
+// b is at least CompressionThreshold bytes. For tiny messages compression
+// costs CPU and can even grow them, Graylog accepts both forms.
+func (g *Gelf) compresses(b []byte) bool {
+	return g.Config.Compression != "none" && len(b) >= g.Config.CompressionThreshold
 }
 
 func (g *Gelf) ParseJson(msg string) map[string]interface{} {
@@ -150,23 +705,374 @@ func (g *Gelf) ParseJson(msg string) map[string]interface{} {
 
 func (g *Gelf) TestForForbiddenValues(gmap map[string]interface{}) error {
 	if _, err := gmap["_id"]; err {
-		return errors.New("Key _id is forbidden")
+		return &ForbiddenFieldError{Field: "_id", Reason: "is forbidden"}
+	}
+
+	return validateFields(gmap)
+}
+
+// testForRequiredValues rejects messages Graylog would discard. With
+// NoDefaults only short_message is required.
+func (g *Gelf) testForRequiredValues(gmap map[string]interface{}) error {
+	for _, k := range []string{"version", "host", "short_message"} {
+		if g.Config.NoDefaults && k != "short_message" {
+			continue
+		}
+		if _, ok := gmap[k]; !ok {
+			return fmt.Errorf("Key %s is required", k)
+		}
+	}
+
+	if short, ok := gmap["short_message"].(string); ok && strings.TrimSpace(short) == "" {
+		return ErrEmptyMessage
 	}
 
 	return nil
 }
 
-func (g *Gelf) Send(b []byte) {
-	var addr = g.Config.GraylogHostname + ":" + strconv.Itoa(g.Config.GraylogPort)
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+func (g *Gelf) Send(b []byte) error {
+	if g.root != nil {
+		return g.root.Send(b)
+	}
+	if g.closed.Load() {
+		return ErrClosed
+	}
+	if g.targets != nil {
+		return g.fanout(g.Config.RequireAllTargets, func(t *Gelf) error {
+			return t.Send(b)
+		})
+	}
+
+	err := g.sendLocked(context.Background(), b)
 	if err != nil {
-		log.Printf("Uh oh! %s", err)
-		return
+		g.onError(err)
 	}
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+
+	return err
+}
+
+func (g *Gelf) sendLocked(ctx context.Context, b []byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.sendPacket(ctx, b)
+}
+
+// onError hands a failed send to Config.OnError. It must not be called
+// while holding g.mu, the handler may log through g again.
+func (g *Gelf) onError(err error) {
+	if g.Config.OnError != nil {
+		g.Config.OnError(err)
+	}
+}
+
+func (g *Gelf) sendPacket(ctx context.Context, b []byte) error {
+	if g.Config.Transport != nil {
+		return g.writeMessage(b)
+	}
+	if g.network() == "tcp" {
+		// GELF over TCP is uncompressed and delimited by a null byte. b may
+		// be the caller's slice of Send, so never append into its capacity.
+		b = append(b[:len(b):len(b)], 0)
+
+		if g.batch != nil {
+			return g.buffer(b)
+		}
+		if g.Config.ReconnectBufferSize > 0 {
+			return g.sendBuffered(ctx, b)
+		}
+	}
+
+	return g.writePacket(ctx, b)
+}
+
+func (g *Gelf) writePacket(ctx context.Context, b []byte) error {
+	err := g.writeRetry(ctx, b)
 	if err != nil {
-		log.Printf("Uh oh! %s", err)
-		return
+		g.stats.sendErrors.Add(1)
+		return err
+	}
+
+	g.stats.bytesSent.Add(uint64(len(b)))
+	return nil
+}
+
+// writeRetry writes b, retrying temporary failures and redialing a stale
+// connection once.
+func (g *Gelf) writeRetry(ctx context.Context, b []byte) error {
+	err := g.write(ctx, b)
+	for attempt := 0; err != nil && attempt < g.Config.MaxRetries && temporary(err); attempt++ {
+		time.Sleep(g.Config.RetryBackoff << uint(attempt))
+		if errors.Is(err, syscall.ECONNRESET) {
+			g.closeConn()
+		}
+		err = g.write(ctx, b)
+	}
+
+	if err != nil && g.conn != nil && !timeout(err) && ctx.Err() == nil {
+		// the connection may have gone stale, e.g. a connected UDP socket
+		// reports ECONNREFUSED until it is redialed: dial once more. A
+		// failed dial left no connection and is not repeated.
+		g.closeConn()
+		err = g.write(ctx, b)
+	}
+
+	return err
+}
+
+func timeout(err error) bool {
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// temporary reports whether a failed write is worth retrying.
+func temporary(err error) bool {
+	if errors.Is(err, syscall.ENOBUFS) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+
+	t, ok := err.(interface{ Temporary() bool })
+	return ok && t.Temporary()
+}
+
+// interruptWrite makes a write on conn fail once ctx is done, even one
+// blocked on a peer that stopped reading. Call the returned func once the
+// write is over.
+func interruptWrite(ctx context.Context, conn net.Conn) func() {
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetWriteDeadline(time.Now())
+		close(interrupted)
+	})
+
+	return func() {
+		if !stop() {
+			// don't let the deadline hit the next write
+			<-interrupted
+		}
+	}
+}
+
+func (g *Gelf) write(ctx context.Context, b []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if g.conn != nil && g.network() == "udp" && g.Config.Dialer == nil {
+		g.refreshAddr()
+	}
+
+	if g.conn == nil {
+		dialCtx := ctx
+		if _, ok := ctx.Deadline(); !ok && g.Config.WriteTimeout > 0 {
+			var cancel context.CancelFunc
+			dialCtx, cancel = context.WithTimeout(ctx, g.Config.WriteTimeout)
+			defer cancel()
+		}
+
+		conn, err := g.connect(dialCtx)
+		if err != nil {
+			return err
+		}
+		g.conn = conn
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok && g.Config.WriteTimeout > 0 {
+		deadline = time.Now().Add(g.Config.WriteTimeout)
+	}
+	g.conn.SetWriteDeadline(deadline)
+	if ctx.Done() != nil {
+		defer interruptWrite(ctx, g.conn)()
+	}
+
+	n, err := g.conn.Write(b)
+	if err == nil && n < len(b) {
+		// a truncated datagram is garbage to Graylog
+		err = fmt.Errorf("Wrote %d of %d bytes: %w", n, len(b), io.ErrShortWrite)
+	}
+
+	return err
+}
+
+// Close flushes and closes the connection, waiting at most CloseTimeout for
+// the async queue to drain if it is set, see Shutdown. On a logger returned by With it
+// only flushes, the connection belongs to the parent.
+func (g *Gelf) Close() error {
+	if g.Config.CloseTimeout <= 0 {
+		return g.Shutdown(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), g.Config.CloseTimeout)
+	defer cancel()
+
+	return g.Shutdown(ctx)
+}
+
+// Shutdown is Close with a deadline for draining the async queue. New
+// messages are rejected with ErrClosed. If ctx is done before the queue is
+// drained, the remaining messages are dropped and the error says how many.
+// A write in flight at that point is interrupted, even on a TCP connection
+// whose peer stopped reading.
+func (g *Gelf) Shutdown(ctx context.Context) error {
+	if g.root != nil {
+		return g.root.Flush()
+	}
+
+	// enqueue checks closed under qmu, so no message is queued after this
+	g.qmu.Lock()
+	closed := g.closed.Swap(true)
+	g.qmu.Unlock()
+	if closed {
+		return ErrClosed
+	}
+	if g.targets != nil {
+		return g.fanout(true, func(t *Gelf) error {
+			return t.Shutdown(ctx)
+		})
+	}
+
+	dropped, stopped := g.stopWorker(ctx)
+	if !stopped {
+		return fmt.Errorf("Shutdown gave up on a message in flight: %w", ctx.Err())
+	}
+	g.stopBatcher()
+	g.stopRetrier()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := g.flushBatch()
+	if rerr := g.flushRetry(ctx); err == nil {
+		err = rerr
+	}
+	g.dropRetry()
+	if cerr := g.closeConn(); err == nil {
+		err = cerr
+	}
+	if g.Config.Transport != nil {
+		if terr := g.Config.Transport.Close(); err == nil {
+			err = terr
+		}
+	}
+	if dropped > 0 {
+		err = fmt.Errorf("Shutdown dropped %d queued messages: %w", dropped, ctx.Err())
+	}
+
+	return err
+}
+
+// setKeepAlive enables TCP keep-alive probes every period, so idle
+// connections are not silently dropped by firewalls and NAT.
+func setKeepAlive(conn net.Conn, period time.Duration) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return err
 	}
-	conn.Write(b)
+
+	return tcpConn.SetKeepAlivePeriod(period)
+}
+
+func (g *Gelf) closeConn() error {
+	if g.conn == nil {
+		return nil
+	}
+
+	err := g.conn.Close()
+	g.conn = nil
+
+	return err
+}
+
+// network returns the network Connection selects. "wan", "lan" and any
+// other value mean UDP. A Transport takes precedence over Connection.
+func (g *Gelf) network() string {
+	if g.Config.Transport != nil {
+		return "transport"
+	}
+
+	switch g.Config.Connection {
+	case "tcp", "unixgram", "http", "discard":
+		return g.Config.Connection
+	default:
+		return "udp"
+	}
+}
+
+func (g *Gelf) connect(ctx context.Context) (net.Conn, error) {
+	if g.Config.Dialer != nil {
+		return g.Config.Dialer(ctx)
+	}
+
+	switch g.network() {
+	case "discard":
+		return discardConn{}, nil
+	case "unixgram":
+		return net.DialUnix("unixgram", nil, &net.UnixAddr{Name: g.Config.UnixSocketPath, Net: "unixgram"})
+	case "udp":
+		udpAddr, err := g.resolveAddr()
+		if err != nil {
+			return nil, err
+		}
+		laddr, err := g.localAddr()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.DialUDP("udp", laddr, udpAddr)
+		if err != nil || g.Config.UDPSendBufferBytes <= 0 {
+			return conn, err
+		}
+		if err := conn.SetWriteBuffer(g.Config.UDPSendBufferBytes); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	}
+
+	// keep-alive is set below, a negative TCPKeepAlive disables it
+	d := net.Dialer{KeepAlive: -1}
+	conn, err := d.DialContext(ctx, "tcp", g.address())
+	if err != nil {
+		return nil, err
+	}
+	if g.Config.TCPKeepAlive > 0 {
+		if err := g.keepAlive(conn, g.Config.TCPKeepAlive); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	tlsConfig := g.tlsConfig()
+	if tlsConfig == nil {
+		return conn, nil
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func (g *Gelf) tlsConfig() *tls.Config {
+	if g.Config.TLS != nil {
+		if g.Config.TLS.ServerName != "" || g.Config.TLS.InsecureSkipVerify {
+			return g.Config.TLS
+		}
+		c := g.Config.TLS.Clone()
+		c.ServerName = g.Config.GraylogHostname
+		return c
+	}
+
+	if g.Config.UseTLS {
+		// a nil RootCAs makes crypto/tls verify against the system pool
+		return &tls.Config{ServerName: g.Config.GraylogHostname}
+	}
+
+	return nil
 }
@@ -2,12 +2,28 @@ package gelf
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
 	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"math"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/bmizerany/assert"
@@ -54,67 +70,1063 @@ func Benchmark_LogWithChunks(b *testing.B) {
 	}
 }
 
+func benchmarkCompress(b *testing.B, compression string) {
+	b.StopTimer()
+	g := New(Config{
+		Compression: compression,
+	})
+
+	var size int
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		buf := g.Compress([]byte(validJson))
+		size = buf.Len()
+	}
+	b.ReportMetric(float64(size), "payload-bytes")
+}
+
+func Benchmark_CompressNone(b *testing.B) {
+	benchmarkCompress(b, "none")
+}
+
+func Benchmark_CompressZlib(b *testing.B) {
+	benchmarkCompress(b, "zlib")
+}
+
+func Benchmark_CompressGzip(b *testing.B) {
+	benchmarkCompress(b, "gzip")
+}
+
 func Test_New_itShouldUseDefaultConfigValuesIfNoOtherProvided(t *testing.T) {
 	g := New(Config{})
 
-	assert.Equal(t, g.Config.GraylogPort, defaultGraylogPort)
-	assert.Equal(t, g.Config.GraylogHostname, defaultGraylogHostname)
-	assert.Equal(t, g.Config.Connection, defaultConnection)
-	assert.Equal(t, g.Config.MaxChunkSizeWan, defaultMaxChunkSizeWan)
-	assert.Equal(t, g.Config.MaxChunkSizeLan, defaultMaxChunkSizeLan)
+	assert.Equal(t, g.Config.GraylogPort, defaultGraylogPort)
+	assert.Equal(t, g.Config.GraylogHostname, defaultGraylogHostname)
+	assert.Equal(t, g.Config.Connection, defaultConnection)
+	assert.Equal(t, g.Config.MaxChunkSizeWan, defaultMaxChunkSizeWan)
+	assert.Equal(t, g.Config.MaxChunkSizeLan, defaultMaxChunkSizeLan)
+	assert.Equal(t, g.Config.Compression, defaultCompression)
+	assert.Equal(t, g.Config.CompressionLevel, zlib.DefaultCompression)
+	assert.Equal(t, g.Config.Host, hostname())
+	assert.Equal(t, g.Config.GelfVersion, "1.1")
+}
+
+func Test_New_itShouldUseTheConfiguredHost(t *testing.T) {
+	g := New(Config{
+		Host: "myhost",
+	})
+
+	assert.Equal(t, g.Config.Host, "myhost")
+}
+
+func Test_hostname_itShouldReturnTheHostname(t *testing.T) {
+	host, _ := os.Hostname()
+
+	assert.Equal(t, host, hostname())
+}
+
+func Test_New_itShouldUseConfigValuesFromArguments(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     80,
+		GraylogHostname: "foobarhost",
+		Connection:      "wlan",
+		MaxChunkSizeWan: 42,
+		MaxChunkSizeLan: 1337,
+	})
+
+	assert.Equal(t, g.Config.GraylogPort, 80)
+	assert.Equal(t, g.Config.GraylogHostname, "foobarhost")
+	assert.Equal(t, g.Config.Connection, "wlan")
+	assert.Equal(t, g.Config.MaxChunkSizeWan, 42)
+	assert.Equal(t, g.Config.MaxChunkSizeLan, 1337)
+}
+
+func Test_ParseJson_itShouldReturnTypeMapStringInterface(t *testing.T) {
+	g := New(Config{})
+	res := g.ParseJson(validJson)
+
+	assert.Equal(t, reflect.TypeOf(res), reflect.TypeOf(make(map[string]interface{})))
+}
+
+func Test_ParseJson_itShouldParseTheStringToJson(t *testing.T) {
+	g := New(Config{})
+	res := g.ParseJson(validJson)
+
+	assert.Equal(t, res["version"], "1.0")
+	assert.Equal(t, res["host"], "localhost")
+	assert.Equal(t, res["timestamp"], "123312312")
+	assert.Equal(t, res["facility"], "Google Go")
+	assert.Equal(t, res["short_message"], "Hello From Golang! :)")
+}
+
+func Test_TestForForbiddenValues_itShouldReturnAnErrorIfForbiddenValuesAppear(t *testing.T) {
+	g := New(Config{})
+	res := g.ParseJson(inValidJson)
+	err := g.TestForForbiddenValues(res)
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldDefaultToLevelInfo(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55568,
+		Compression: "none",
+	})
+
+	received := UdpServer(55568)
+	g.Log(validJson)
+
+	assert.Equal(t, float64(LevelInfo), g.ParseJson(string(<-received))["level"])
+}
+
+func Test_Log_itShouldKeepAnExplicitLevel(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55569,
+		Compression: "none",
+	})
+
+	received := UdpServer(55569)
+	g.Log(`{"version": "1.0", "host": "localhost", "short_message": "Hello", "level": 2}`)
+
+	assert.Equal(t, float64(LevelCritical), g.ParseJson(string(<-received))["level"])
+}
+
+func Test_Log_itShouldWrapBareMessages(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55572,
+		Compression: "none",
+		Host:        "myhost",
+	})
+
+	received := UdpServer(55572)
+	g.Log("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "myhost", res["host"])
+}
+
+func Test_Log_itShouldFillAMissingHost(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55573,
+		Compression: "none",
+		Host:        "myhost",
+	})
+
+	received := UdpServer(55573)
+	g.Log(`{"version": "1.0", "short_message": "Hello Graylog"}`)
+
+	assert.Equal(t, "myhost", g.ParseJson(string(<-received))["host"])
+}
+
+func Test_Log_itShouldSetTheTimestamp(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55584,
+		Compression: "none",
+	})
+
+	received := UdpServer(55584)
+	g.Log("Hello Graylog")
+
+	timestamp, ok := g.ParseJson(string(<-received))["timestamp"].(float64)
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, math.Abs(now-timestamp) < 1)
+}
+
+func Test_Log_itShouldUseTheConfiguredClock(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55621,
+		Compression: "none",
+		Now: func() time.Time {
+			return time.Unix(1356262644, 500000000)
+		},
+	})
+
+	received := UdpServer(55621)
+	g.Log("Hello Graylog")
+
+	assert.Equal(t, 1356262644.5, g.ParseJson(string(<-received))["timestamp"])
+}
+
+func Test_Log_itShouldKeepAnExplicitTimestamp(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55585,
+		Compression: "none",
+	})
+
+	received := UdpServer(55585)
+	g.Log(validJson)
+
+	assert.Equal(t, float64(123312312), g.ParseJson(string(<-received))["timestamp"])
+}
+
+func Test_LogFull_itShouldSendShortAndFullMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55587,
+		Compression: "none",
+	})
+
+	received := UdpServer(55587)
+	err := g.LogFull("Something failed", "Something failed\n\tat main.go:42")
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Something failed", res["short_message"])
+	assert.Equal(t, "Something failed\n\tat main.go:42", res["full_message"])
+}
+
+func Test_LogJSON_itShouldSendTheObject(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55588,
+		Compression: "none",
+	})
+
+	received := UdpServer(55588)
+	err := g.LogJSON(map[string]interface{}{
+		"version":       "1.0",
+		"host":          "localhost",
+		"short_message": "Hello Graylog",
+		"_user":         "robert",
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "1.0", res["version"])
+	assert.Equal(t, "localhost", res["host"])
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "robert", res["_user"])
+}
+
+func Test_LogJSON_itShouldFillMissingCoreFields(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55589,
+		Compression: "none",
+		Host:        "myhost",
+	})
+
+	obj := map[string]interface{}{"short_message": "Hello Graylog"}
+	received := UdpServer(55589)
+	g.LogJSON(obj)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "1.1", res["version"])
+	assert.Equal(t, "myhost", res["host"])
+	assert.Equal(t, 1, len(obj))
+}
+
+func Test_Info_itShouldUseTheConfiguredVersion(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55598,
+		Compression: "none",
+		GelfVersion: "1.0",
+	})
+
+	received := UdpServer(55598)
+	g.Info("Hello Graylog")
+
+	assert.Equal(t, "1.0", g.ParseJson(string(<-received))["version"])
+}
+
+func Test_Log_itShouldSendTheFacilityAsAnAdditionalField(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55616,
+		Compression: "none",
+		Facility:    "billing",
+	})
+
+	received := UdpServer(55616)
+	g.Info("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "billing", res["_facility"])
+	assert.Equal(t, nil, res["facility"])
+}
+
+func Test_Log_itShouldSendTheFacilityAsACoreFieldForGelf10(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55617,
+		Compression: "none",
+		Facility:    "billing",
+		GelfVersion: "1.0",
+	})
+
+	received := UdpServer(55617)
+	g.Info("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "billing", res["facility"])
+	assert.Equal(t, nil, res["_facility"])
+}
+
+func Test_Log_itShouldKeepTheFacilityOfTheMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55618,
+		Compression: "none",
+		Facility:    "billing",
+	})
+
+	received := UdpServer(55618)
+	g.Log(validJson)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Google Go", res["facility"])
+	assert.Equal(t, nil, res["_facility"])
+}
+
+func Test_Log_itShouldSendTheSourceApartFromTheHost(t *testing.T) {
+	g := New(Config{
+		Compression: "none",
+		Host:        "web-1",
+		Source:      "billing",
+	})
+
+	b, _ := g.BuildMessage("Hello Graylog")
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "web-1", res["host"])
+	assert.Equal(t, "billing", res["_source"])
+	assert.Equal(t, nil, res["source"])
+
+	g = New(Config{
+		Compression: "none",
+		Host:        "web-1",
+		Source:      "billing",
+		GelfVersion: "1.0",
+	})
+
+	b, _ = g.BuildMessage("Hello Graylog")
+
+	res = g.ParseJson(string(b))
+	assert.Equal(t, "web-1", res["host"])
+	assert.Equal(t, "billing", res["source"])
+}
+
+func Test_LogJSON_itShouldNotFillDefaultsWithNoDefaults(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55626,
+		Compression: "none",
+		NoDefaults:  true,
+	})
+
+	received := UdpServer(55626)
+	err := g.LogJSON(map[string]interface{}{
+		"version":       "1.1",
+		"short_message": "Hello Graylog",
+	})
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"version":       "1.1",
+		"short_message": "Hello Graylog",
+	}, g.ParseJson(string(<-received)))
+}
+
+func Test_LogJSON_itShouldStillRejectForbiddenValuesWithNoDefaults(t *testing.T) {
+	g := New(Config{
+		NoDefaults: true,
+	})
+
+	err := g.LogJSON(map[string]interface{}{
+		"short_message": "Hello Graylog",
+		"_id":           "23",
+	})
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldIncludeThePID(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55629,
+		Compression: "none",
+		IncludePID:  true,
+	})
+
+	received := UdpServer(55629)
+	g.With(nil).Info("Hello Graylog")
+
+	assert.Equal(t, float64(os.Getpid()), g.ParseJson(string(<-received))["_pid"])
+}
+
+func Test_LogJSON_itShouldRequireAShortMessage(t *testing.T) {
+	g := New(Config{})
+	err := g.LogJSON(map[string]interface{}{"host": "localhost"})
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldRejectAnEmptyShortMessage(t *testing.T) {
+	g := New(Config{})
+
+	assert.Equal(t, ErrEmptyMessage, g.Log(""))
+	assert.Equal(t, ErrEmptyMessage, g.Log(" \n\t"))
+}
+
+func Test_LogJSON_itShouldRejectForbiddenValues(t *testing.T) {
+	g := New(Config{})
+	err := g.LogJSON(g.ParseJson(inValidJson))
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_LogReader_itShouldSendTheGelfObject(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55635,
+		Compression: "none",
+	})
+
+	received := UdpServer(55635)
+	err := g.LogReader(strings.NewReader(`{"short_message":"Hello Graylog","_user":"robert"}`))
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, "1.1", res["version"])
+}
+
+func Test_LogReader_itShouldReturnReadErrors(t *testing.T) {
+	g := New(Config{})
+	r := io.MultiReader(strings.NewReader(`{"short_message":`), iotest.ErrReader(io.ErrClosedPipe))
+
+	assert.Equal(t, io.ErrClosedPipe, g.LogReader(r))
+	assert.NotEqual(t, nil, g.LogReader(strings.NewReader("Hello Graylog")))
+	assert.NotEqual(t, nil, g.LogReader(strings.NewReader(inValidJson)))
+}
+
+func Test_Log_itShouldReturnAnErrorIfForbiddenValuesAppear(t *testing.T) {
+	g := New(Config{})
+	err := g.Log(inValidJson)
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldReturnAnErrorIfGraylogIsUnreachable(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55560,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+	})
+	err := g.Log(validJson)
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldCompressMessagesWithZlibByDefault(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55561,
+	})
+
+	received := UdpServer(55561)
+	g.Log(validJson)
+
+	r, err := zlib.NewReader(bytes.NewReader(<-received))
+	assert.Equal(t, nil, err)
+	res, err := io.ReadAll(r)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(res))["short_message"])
+}
+
+func Test_Log_itShouldCompressMessagesWithGzip(t *testing.T) {
+	g := New(Config{
+		GraylogPort:      55563,
+		Compression:      "gzip",
+		CompressionLevel: gzip.BestCompression,
+	})
+
+	received := UdpServer(55563)
+	g.Log(validJson)
+
+	b := <-received
+	assert.Equal(t, []byte("\x1f\x8b"), b[:2])
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	assert.Equal(t, nil, err)
+	res, err := io.ReadAll(r)
+	assert.Equal(t, nil, err)
+
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(res))["short_message"])
+}
+
+func Test_Log_itShouldChunkGzipMessages(t *testing.T) {
+	waitChan := make(chan []byte, 1)
+	daeCfg := graylogd.Config{
+		ListenAddr: "127.0.0.1:2212",
+		HandleRaw: func(b []byte) {
+			waitChan <- b
+		},
+		HandleError: func(addr *net.UDPAddr, err error) {
+			t.Fatal("should be no error", err)
+		},
+	}
+	logd, err := graylogd.NewGraylogd(daeCfg)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, logd.Run())
+	defer logd.Close()
+
+	g := New(Config{
+		GraylogPort:     2212,
+		GraylogHostname: "127.0.0.1",
+		Compression:     "gzip",
+		MaxChunkSizeWan: 10,
+	})
+	g.Log(validJson)
+
+	select {
+	case b := <-waitChan:
+		assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(b))["short_message"])
+	case <-time.After(time.Second):
+		t.Fatal("message is not received")
+	}
+}
+
+func Test_Log_itShouldNotCompressMessagesWithCompressionNone(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55562,
+		Compression: "none",
+	})
+
+	received := UdpServer(55562)
+	g.Log(validJson)
+
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(<-received))["short_message"])
+}
+
+func Test_Log_itShouldOnlyCompressMessagesAboveTheThreshold(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		CompressionThreshold: 200,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.Log("Hello Graylog")
+	g.Log(strings.Repeat("Hello Graylog", 20))
+
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(conn.writes[0]))["short_message"])
+
+	r, err := zlib.NewReader(bytes.NewReader(conn.writes[1]))
+	assert.Equal(t, nil, err)
+	res, _ := io.ReadAll(r)
+	assert.Equal(t, strings.Repeat("Hello Graylog", 20), g.ParseJson(string(res))["short_message"])
+}
+
+func Test_Write_itShouldSendTheLineAsShortMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55564,
+		Compression: "none",
+	})
+
+	received := UdpServer(55564)
+	n, err := g.Write([]byte("Hello Graylog\n"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 14, n)
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "1.1", res["version"])
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+}
+
+func Test_Write_itShouldWorkWithTheStandardLogger(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55565,
+		Compression: "none",
+	})
+
+	received := UdpServer(55565)
+	log.New(g, "prefix: ", 0).Print("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "prefix: Hello Graylog", res["short_message"])
+}
+
+func Test_NewStdLogger_itShouldSendLinesWithPrefixAndFlags(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55601,
+		Compression: "none",
+	})
+
+	received := UdpServer(55601)
+	NewStdLogger(g, "app: ", log.Lshortfile).Print("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	msg := res["short_message"].(string)
+	assert.Equal(t, true, strings.HasPrefix(msg, "app: gelf_test.go:"))
+	assert.Equal(t, true, strings.HasSuffix(msg, ": Hello Graylog"))
+}
+
+func Test_Write_itShouldReturnAnErrorIfGraylogIsUnreachable(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55566,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+	})
+
+	n, err := g.Write([]byte("Hello Graylog\n"))
+	assert.Equal(t, 0, n)
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_TestSend_itShouldSendUdpPacketsToAServer(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55555,
+	})
+
+	done := Server(55555, t)
+	g.Send([]byte("Hello Graylog"))
+	<-done
+}
+
+func Test_TestSend_itShouldSendToIpv6Hosts(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv6loopback, Port: 55593})
+	if err != nil {
+		t.Skip("IPv6 is not available:", err)
+	}
+	defer conn.Close()
+
+	g := New(Config{
+		GraylogPort:     55593,
+		GraylogHostname: "::1",
+	})
+	defer g.Close()
+
+	assert.Equal(t, nil, g.Send([]byte("Hello Graylog")))
+
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buffer)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Hello Graylog", string(buffer[:n]))
+}
+
+func Test_Log_itShouldSendToAUnixDatagramSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gelf.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	g := New(Config{
+		Connection:     "unixgram",
+		UnixSocketPath: path,
+		Compression:    "none",
+	})
+	defer g.Close()
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+
+	buffer := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buffer)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(buffer[:n]))["short_message"])
+}
+
+func Test_Dialer_itShouldBeUsedInsteadOfDialing(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	g := New(Config{
+		GraylogHostname: "unresolvable.invalid",
+		Compression:     "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return client, nil
+		},
+	})
+	defer g.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, 1024)
+		n, _ := server.Read(buffer)
+		received <- buffer[:n]
+	}()
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
+}
+
+func Test_TestSend_itShouldReuseTheConnection(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55575,
+	})
+	defer g.Close()
+
+	received := UdpServer(55575)
+	g.Send([]byte("Hello Graylog"))
+	<-received
+	conn := g.conn
+
+	received = UdpServer(55575)
+	g.Send([]byte("Hello Graylog"))
+	<-received
+
+	assert.Equal(t, conn, g.conn)
+}
+
+func Test_TestSend_itShouldRedialAfterAFailedWrite(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55576,
+	})
+	defer g.Close()
+
+	received := UdpServer(55576)
+	g.Send([]byte("Hello Graylog"))
+	<-received
+	g.conn.Close()
+
+	received = UdpServer(55576)
+	err := g.Send([]byte("Hello Graylog"))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+}
+
+func Test_Close_itShouldReleaseTheConnection(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55577,
+	})
+
+	received := UdpServer(55577)
+	g.Send([]byte("Hello Graylog"))
+	<-received
+
+	assert.Equal(t, nil, g.Close())
+	assert.Equal(t, nil, g.conn)
+}
+
+func Test_TestSend_itShouldTimeOutWhenTheServerDoesNotRead(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:55581")
+	assert.Equal(t, nil, err)
+	defer l.Close()
+
+	g := New(Config{
+		GraylogPort:     55581,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		WriteTimeout:    100 * time.Millisecond,
+	})
+	defer g.Close()
+
+	err = g.Send(make([]byte, 64<<20))
+
+	ne, ok := err.(net.Error)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, ne.Timeout())
+}
+
+func Test_TestSend_itShouldRetryTemporaryFailures(t *testing.T) {
+	g := New(Config{
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+	conn := &fakeConn{failures: 3, err: temporaryError{}}
+	g.conn = conn
+
+	err := g.Send([]byte("Hello Graylog"))
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, [][]byte{[]byte("Hello Graylog")}, conn.writes)
+}
+
+func Test_TestSend_itShouldGiveUpAfterMaxRetries(t *testing.T) {
+	g := New(Config{
+		GraylogPort:  55582,
+		MaxRetries:   2,
+		RetryBackoff: time.Millisecond,
+	})
+	conn := &fakeConn{failures: 3, err: temporaryError{}}
+	g.conn = conn
+
+	received := UdpServer(55582)
+	err := g.Send([]byte("Hello Graylog"))
+
+	// after the retries the connection is dialed once more
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 0, len(conn.writes))
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+}
+
+func Test_TestSend_itShouldOnlyRedialOnceByDefault(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55583,
+	})
+	conn := &fakeConn{failures: 1, err: temporaryError{}}
+	g.conn = conn
+
+	received := UdpServer(55583)
+	g.Send([]byte("Hello Graylog"))
+
+	assert.Equal(t, 0, len(conn.writes))
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+}
+
+// shortConn is a net.Conn writing only half of every buffer.
+type shortConn struct {
+	net.Conn
+}
+
+func (shortConn) Write(b []byte) (int, error)        { return len(b) / 2, nil }
+func (shortConn) SetWriteDeadline(t time.Time) error { return nil }
+func (shortConn) Close() error                       { return nil }
+
+func Test_TestSend_itShouldReturnAnErrorForShortWrites(t *testing.T) {
+	g := New(Config{
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return shortConn{}, nil
+		},
+	})
+
+	err := g.Send([]byte("Hello Graylog"))
+
+	assert.Equal(t, true, errors.Is(err, io.ErrShortWrite))
+	assert.Equal(t, "Wrote 6 of 13 bytes: short write", err.Error())
+	assert.Equal(t, uint64(1), g.Stats().SendErrors)
+}
+
+func Test_temporary_itShouldClassifyErrors(t *testing.T) {
+	assert.Equal(t, true, temporary(temporaryError{}))
+	assert.Equal(t, true, temporary(&net.OpError{Op: "write", Err: syscall.ENOBUFS}))
+	assert.Equal(t, true, temporary(&net.OpError{Op: "write", Err: syscall.ECONNRESET}))
+	assert.Equal(t, false, temporary(errors.New("permanent")))
+}
+
+func Test_OnError_itShouldBeCalledWhenASendFails(t *testing.T) {
+	var errs []error
+	g := New(Config{
+		GraylogPort:     55591,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		OnError: func(err error) {
+			errs = append(errs, err)
+		},
+	})
+
+	err := g.Log("Hello Graylog")
+
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, err, errs[0])
+}
+
+func Test_OnError_itShouldAllowLoggingFromTheHandler(t *testing.T) {
+	var g *Gelf
+	called := 0
+	g = New(Config{
+		GraylogPort:     55592,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		OnError: func(err error) {
+			called++
+			if called == 1 {
+				g.Log("logging failed: " + err.Error())
+			}
+		},
+	})
+
+	g.Log("Hello Graylog")
+
+	assert.Equal(t, 2, called)
+}
+
+func Test_TestSend_itShouldTerminateTcpMessagesWithANullByte(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55556,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+	})
+
+	received := TcpServer(55556)
+	g.Send([]byte("Hello Graylog"))
+	g.Close()
+
+	assert.Equal(t, []byte("Hello Graylog\x00"), <-received)
+}
+
+func Test_TestSend_itShouldNotWriteIntoTheCallersSlice(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Connection: "tcp",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	buf := []byte("Hello Graylog!")
+	assert.Equal(t, nil, g.Send(buf[:13]))
+
+	assert.Equal(t, []byte("Hello Graylog!"), buf)
+	assert.Equal(t, []byte("Hello Graylog\x00"), conn.writes[0])
+}
+
+func Test_TestSend_itShouldSendTcpMessagesOverTls(t *testing.T) {
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+	rootCAs := ts.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+
+	g := New(Config{
+		GraylogPort:     55558,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		TLS:             &tls.Config{RootCAs: rootCAs},
+	})
+
+	received := TlsServer(55558, ts.TLS)
+	g.Send([]byte("Hello Graylog"))
+	g.Close()
+
+	assert.Equal(t, []byte("Hello Graylog\x00"), <-received)
+}
+
+func Test_connect_itShouldFailTheHandshakeForUntrustedCertificates(t *testing.T) {
+	ts := httptest.NewTLSServer(nil)
+	defer ts.Close()
+
+	g := New(Config{
+		GraylogPort:     55559,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		UseTLS:          true,
+	})
+
+	TlsServer(55559, ts.TLS)
+	conn, err := g.connect(context.Background())
+
+	assert.Equal(t, nil, conn)
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_connect_itShouldEnableTcpKeepAlive(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55636,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		TCPKeepAlive:    10 * time.Second,
+	})
+
+	var enabled net.Conn
+	var period time.Duration
+	g.keepAlive = func(conn net.Conn, p time.Duration) error {
+		enabled, period = conn, p
+		return setKeepAlive(conn, p)
+	}
+
+	TcpServer(55636)
+	conn, err := g.connect(context.Background())
+	assert.Equal(t, nil, err)
+	defer conn.Close()
+
+	assert.Equal(t, conn, enabled)
+	assert.Equal(t, 10*time.Second, period)
 }
 
-func Test_New_itShouldUseConfigValuesFromArguments(t *testing.T) {
+func Test_connect_itShouldDisableTcpKeepAliveForNegativeValues(t *testing.T) {
 	g := New(Config{
-		GraylogPort:     80,
-		GraylogHostname: "foobarhost",
-		Connection:      "wlan",
-		MaxChunkSizeWan: 42,
-		MaxChunkSizeLan: 1337,
+		GraylogPort:     55637,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		TCPKeepAlive:    -1,
 	})
 
-	assert.Equal(t, g.Config.GraylogPort, 80)
-	assert.Equal(t, g.Config.GraylogHostname, "foobarhost")
-	assert.Equal(t, g.Config.Connection, "wlan")
-	assert.Equal(t, g.Config.MaxChunkSizeWan, 42)
-	assert.Equal(t, g.Config.MaxChunkSizeLan, 1337)
+	called := false
+	g.keepAlive = func(conn net.Conn, p time.Duration) error {
+		called = true
+		return nil
+	}
+
+	TcpServer(55637)
+	conn, err := g.connect(context.Background())
+	assert.Equal(t, nil, err)
+	conn.Close()
+
+	assert.Equal(t, false, called)
+	assert.Equal(t, 30*time.Second, New(Config{}).TCPKeepAlive)
 }
 
-func Test_ParseJson_itShouldReturnTypeMapStringInterface(t *testing.T) {
-	g := New(Config{})
-	res := g.ParseJson(validJson)
+func Test_Log_itShouldNotCompressOrChunkTcpMessages(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55557,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+		MaxChunkSizeWan: 1,
+		MaxChunkSizeLan: 1,
+	})
 
-	assert.Equal(t, reflect.TypeOf(res), reflect.TypeOf(make(map[string]interface{})))
+	received := TcpServer(55557)
+	g.Log(validJson)
+	g.Close()
+
+	b := <-received
+	assert.Equal(t, byte(0), b[len(b)-1])
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(b[:len(b)-1]))["short_message"])
 }
 
-func Test_ParseJson_itShouldParseTheStringToJson(t *testing.T) {
-	g := New(Config{})
-	res := g.ParseJson(validJson)
+func Test_Log_itShouldRedialAfterUdpConnectionRefused(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55638,
+		GraylogHostname: "127.0.0.1",
+		Compression:     "none",
+	})
 
-	assert.Equal(t, res["version"], "1.0")
-	assert.Equal(t, res["host"], "localhost")
-	assert.Equal(t, res["timestamp"], "123312312")
-	assert.Equal(t, res["facility"], "Google Go")
-	assert.Equal(t, res["short_message"], "Hello From Golang! :)")
+	// nobody listens yet, the ICMP port unreachable is reported on the
+	// connected socket's next write
+	g.Log("lost")
+	time.Sleep(50 * time.Millisecond)
+
+	received := UdpServer(55638)
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
 }
 
-func Test_TestForForbiddenValues_itShouldReturnAnErrorIfForbiddenValuesAppear(t *testing.T) {
-	g := New(Config{})
-	res := g.ParseJson(inValidJson)
-	err := g.TestForForbiddenValues(res)
+func Test_Log_itShouldRedialOnceAfterConnectionRefused(t *testing.T) {
+	refused := &fakeConn{failures: 1, err: syscall.ECONNREFUSED}
+	healthy := &fakeConn{}
+	dials := 0
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			if dials == 1 {
+				return refused, nil
+			}
+			return healthy, nil
+		},
+	})
 
-	assert.NotEqual(t, nil, err)
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, 2, dials)
+	assert.Equal(t, 1, len(healthy.writes))
 }
 
-func Test_TestSend_itShouldSendUdpPacketsToAServer(t *testing.T) {
+func Test_Log_itShouldNotDialTwiceWhenTheDialFails(t *testing.T) {
+	dials := 0
 	g := New(Config{
-		GraylogPort: 55555,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			return nil, errors.New("connection refused")
+		},
 	})
 
-	done := make(chan int)
-	go Server(done, 55555, t)
-	g.Send([]byte("Hello Graylog"))
-	<-done
+	assert.Equal(t, "connection refused", g.Log("Hello Graylog").Error())
+	assert.Equal(t, 1, dials)
+}
+
+func Test_Log_itShouldBoundTheDialByTheWriteTimeout(t *testing.T) {
+	g := New(Config{
+		WriteTimeout: 20 * time.Millisecond,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+
+	start := time.Now()
+	err := g.Log("Hello Graylog")
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Log took %s, expected the dial to time out", elapsed)
+	}
 }
 
 func Test_IntToBytes_itShouldCreateBytesFromInts(t *testing.T) {
@@ -151,6 +1163,178 @@ func Test_GetChunksize_itShouldReturnTheValuesForLan(t *testing.T) {
 	assert.Equal(t, 1337, res)
 }
 
+func Test_GetChunksize_itShouldFallBackToWanForUnknownConnections(t *testing.T) {
+	g := New(Config{
+		Connection:      "wlan",
+		MaxChunkSizeWan: 42,
+		MaxChunkSizeLan: 1337,
+	})
+
+	res := g.GetChunksize()
+
+	assert.Equal(t, 42, res)
+}
+
+func Test_New_itShouldReplaceNonPositiveChunkSizes(t *testing.T) {
+	g := New(Config{
+		MaxChunkSizeWan: 0,
+		MaxChunkSizeLan: -5,
+	})
+
+	assert.Equal(t, 1420, g.Config.MaxChunkSizeWan)
+	assert.Equal(t, 8154, g.Config.MaxChunkSizeLan)
+}
+
+func Test_Log_itShouldNotHangWithAZeroChunkSize(t *testing.T) {
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return &fakeConn{}, nil
+		},
+	})
+	g.Config.MaxChunkSizeWan = 0
+
+	assert.Equal(t, 1420, g.GetChunksize())
+	assert.Equal(t, nil, g.Log(strings.Repeat("Hello Graylog", 1000)))
+	assert.Equal(t, uint64(10), g.Stats().ChunksSent)
+}
+
+func Test_GetChunksize_itShouldIgnoreTheCaseOfTheConnection(t *testing.T) {
+	for connection, size := range map[string]int{"WAN": 42, "Wan": 42, " wan ": 42, "LAN": 1337, " Lan\n": 1337} {
+		g := New(Config{
+			Connection:      connection,
+			MaxChunkSizeWan: 42,
+			MaxChunkSizeLan: 1337,
+		})
+
+		assert.Equal(t, size, g.GetChunksize())
+	}
+}
+
+func Test_New_itShouldNormalizeTheConnection(t *testing.T) {
+	g := New(Config{Connection: " TCP "})
+
+	assert.Equal(t, "tcp", g.Config.Connection)
+	assert.Equal(t, "tcp", g.network())
+}
+
+func Test_GetChunksize_itShouldDeriveTheSizeFromThePathMTU(t *testing.T) {
+	g := New(Config{
+		PathMTU: 1500,
+	})
+
+	assert.Equal(t, 1500-48-12, g.GetChunksize())
+}
+
+func Test_GetChunksize_itShouldPreferExplicitSizesOverThePathMTU(t *testing.T) {
+	g := New(Config{
+		PathMTU:         1500,
+		MaxChunkSizeWan: 42,
+	})
+
+	assert.Equal(t, 42, g.GetChunksize())
+}
+
+func Test_EffectiveChunkDataSize_itShouldMatchTheConfiguredChunkSize(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 100,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	assert.Equal(t, 100, g.EffectiveChunkDataSize())
+	assert.Equal(t, g.GetChunksize(), g.EffectiveChunkDataSize())
+
+	g.Log(strings.Repeat("Hello Graylog", 100))
+	assert.Equal(t, 12+g.EffectiveChunkDataSize(), len(conn.writes[0]))
+}
+
+func Test_chunkSizeForMTU_itShouldIgnoreTooSmallMTUs(t *testing.T) {
+	assert.Equal(t, 1420, chunkSizeForMTU(60, 1420))
+	assert.Equal(t, 1420, chunkSizeForMTU(0, 1420))
+}
+
+func Test_Chunks_itShouldSplitThePayload(t *testing.T) {
+	id := []byte("abcdefgh")
+	chunks := Chunks([]byte("Hello Graylog"), 5, id)
+
+	assert.Equal(t, 3, len(chunks))
+	assert.Equal(t, "\x1e\x0fabcdefgh\x00\x03Hello", string(chunks[0]))
+	assert.Equal(t, "\x1e\x0fabcdefgh\x01\x03 Gray", string(chunks[1]))
+	assert.Equal(t, "\x1e\x0fabcdefgh\x02\x03log", string(chunks[2]))
+}
+
+func Test_Chunks_itShouldNotChunkSmallPayloads(t *testing.T) {
+	chunks := Chunks([]byte("Hello Graylog"), 13, []byte("abcdefgh"))
+
+	assert.Equal(t, [][]byte{[]byte("Hello Graylog")}, chunks)
+}
+
+func Test_Chunks_itShouldHandleTheChunkSizeBoundary(t *testing.T) {
+	id := []byte("abcdefgh")
+
+	assert.Equal(t, 1, len(Chunks(make([]byte, 99), 100, id)))
+	assert.Equal(t, 1, len(Chunks(make([]byte, 100), 100, id)))
+
+	chunks := Chunks(make([]byte, 101), 100, id)
+	assert.Equal(t, 2, len(chunks))
+	assert.Equal(t, 12+100, len(chunks[0]))
+	assert.Equal(t, 12+1, len(chunks[1]))
+
+	chunks = Chunks(make([]byte, 200), 100, id)
+	assert.Equal(t, 2, len(chunks))
+	assert.Equal(t, 12+100, len(chunks[1]))
+}
+
+func Test_transmit_itShouldHandleTheChunkSizeBoundary(t *testing.T) {
+	size := 100
+	for payload, datagrams := range map[int]int{size - 1: 1, size: 1, size + 1: 2} {
+		conn := &fakeConn{}
+		g := New(Config{
+			Compression:     "none",
+			MaxChunkSizeWan: size,
+			Dialer: func(ctx context.Context) (net.Conn, error) {
+				return conn, nil
+			},
+		})
+
+		assert.Equal(t, nil, g.transmit(context.Background(), make([]byte, payload)))
+		assert.Equal(t, datagrams, len(conn.writes))
+		for _, b := range conn.writes {
+			assert.Equal(t, true, len(b) > chunkHeaderSize)
+		}
+	}
+}
+
+func Test_Chunks_itShouldRejectTooManyChunks(t *testing.T) {
+	assert.Equal(t, 128, len(Chunks(make([]byte, 128), 1, []byte("abcdefgh"))))
+	assert.Equal(t, true, Chunks(make([]byte, 129), 1, []byte("abcdefgh")) == nil)
+}
+
+func Test_ChunkHeader_itShouldParseTheHeader(t *testing.T) {
+	g := New(Config{})
+	packet := g.CreateChunkedMessage(2, 5, []byte("abcdefgh"), bytes.NewBufferString("Hello Graylog"))
+
+	assert.Equal(t, true, IsChunked(packet.Bytes()))
+
+	id, seq, total, ok := ChunkHeader(packet.Bytes())
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []byte("abcdefgh"), id)
+	assert.Equal(t, byte(2), seq)
+	assert.Equal(t, byte(5), total)
+}
+
+func Test_ChunkHeader_itShouldRejectOtherDatagrams(t *testing.T) {
+	assert.Equal(t, false, IsChunked([]byte(`{"short_message":"Hello Graylog"}`)))
+	assert.Equal(t, false, IsChunked([]byte{0x1e, 0x0f, 'a'}))
+
+	_, _, _, ok := ChunkHeader([]byte("Hello Graylog"))
+	assert.Equal(t, false, ok)
+}
+
 func Test_CreateChunkedMessages_itShouldStartWithTheMagicNumber(t *testing.T) {
 	g := New(Config{})
 	b := []byte("message")
@@ -165,11 +1349,13 @@ func Test_CreateChunkedMessages_itShouldStartWithTheMagicNumber(t *testing.T) {
 func Test_ChunkSize(t *testing.T) {
 
 	waitChan := make(chan bool, 1)
-	var realB []byte
+	var realMsg string
 	daeCfg := graylogd.Config{
 		ListenAddr: "127.0.0.1:2211",
 		HandleRaw: func(b []byte) {
-			assert.Equal(t, realB, b)
+			var res map[string]interface{}
+			json.Unmarshal(b, &res)
+			assert.Equal(t, realMsg, res["short_message"])
 			waitChan <- true
 		},
 		HandleError: func(addr *net.UDPAddr, err error) {
@@ -194,7 +1380,7 @@ func Test_ChunkSize(t *testing.T) {
 	}
 	for _, msg := range msgs {
 
-		realB = []byte(msg)
+		realMsg = msg
 
 		client.Log(msg)
 		select {
@@ -205,6 +1391,151 @@ func Test_ChunkSize(t *testing.T) {
 	}
 }
 
+func Test_Log_itShouldRejectMessagesNeedingMoreThan128Chunks(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55574,
+		Compression:     "none",
+		MaxChunkSizeWan: 1,
+	})
+
+	err := g.Log(strings.Repeat("a", 129))
+
+	assert.NotEqual(t, nil, err)
+}
+
+func Test_Log_itShouldNotChunkMessagesThatFitInOneDatagram(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55596,
+	})
+
+	received := UdpServer(55596)
+	g.Log("Hello Graylog")
+
+	assert.Equal(t, false, bytes.HasPrefix(<-received, []byte("\x1e\x0f")))
+}
+
+func Test_Log_itShouldChunkMessagesLargerThanTheChunkSize(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55597,
+		MaxChunkSizeWan: 10,
+	})
+
+	received := UdpServer(55597)
+	g.Log("Hello Graylog")
+
+	assert.Equal(t, true, bytes.HasPrefix(<-received, []byte("\x1e\x0f")))
+}
+
+func Test_generateMessageID_itShouldReturnEightRandomBytes(t *testing.T) {
+	id := generateMessageID()
+
+	assert.Equal(t, 8, len(id))
+	assert.NotEqual(t, id, generateMessageID())
+}
+
+func Test_Log_itShouldChunkUncompressedMessages(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 10,
+		Now: func() time.Time {
+			return time.Unix(1356262644, 0)
+		},
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	expected, err := g.BuildMessage("Hello Graylog")
+	assert.Equal(t, nil, err)
+
+	var payload []byte
+	for i, chunk := range conn.writes {
+		assert.Equal(t, []byte{0x1e, 0x0f}, chunk[:2])
+		assert.Equal(t, byte(i), chunk[10])
+		assert.Equal(t, byte(len(conn.writes)), chunk[11])
+		payload = append(payload, chunk[12:]...)
+	}
+
+	assert.Equal(t, true, len(conn.writes) > 1)
+	assert.Equal(t, string(expected), string(payload))
+}
+
+func Test_Log_itShouldPaceChunksWithChunkSendDelay(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 100,
+		ChunkSendDelay:  5 * time.Millisecond,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	start := time.Now()
+	assert.Equal(t, nil, g.Log(strings.Repeat("a", 1000)))
+	elapsed := time.Since(start)
+
+	chunks := len(conn.writes)
+	assert.Equal(t, true, chunks > 10)
+	if min := time.Duration(chunks-1) * 5 * time.Millisecond; elapsed < min {
+		t.Errorf("sending %d chunks took %s, expected at least %s", chunks, elapsed, min)
+	}
+}
+
+func Test_Log_itShouldBeSafeForConcurrentUse(t *testing.T) {
+	const count = 200
+
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 20,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+	defer g.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			assert.Equal(t, nil, g.Log("message "+strconv.Itoa(i)))
+		}(i)
+	}
+	wg.Wait()
+
+	// the chunks of each message must be written together and in order
+	received := map[string]bool{}
+	r := Reassembler{}
+	for i := 0; i < len(conn.writes); {
+		id, _, total, ok := ChunkHeader(conn.writes[i])
+		assert.Equal(t, true, ok)
+		if i+int(total) > len(conn.writes) {
+			t.Fatalf("message %x is missing chunks", id)
+		}
+
+		for seq := 0; seq < int(total); seq++ {
+			chunkID, chunkSeq, _, _ := ChunkHeader(conn.writes[i+seq])
+			assert.Equal(t, id, chunkID)
+			assert.Equal(t, byte(seq), chunkSeq)
+
+			payload, complete, err := r.Add(conn.writes[i+seq])
+			assert.Equal(t, nil, err)
+			assert.Equal(t, seq == int(total)-1, complete)
+			if complete {
+				received[g.ParseJson(string(payload))["short_message"].(string)] = true
+			}
+		}
+		i += int(total)
+	}
+
+	assert.Equal(t, count, len(received))
+	assert.Equal(t, 0, r.Pending())
+}
+
 func Test_CreateChunkedMessages_itShouldContainAnId(t *testing.T) {
 	g := New(Config{})
 	b := []byte("message")
@@ -242,31 +1573,114 @@ func Test_CreateChunkedMessages_itShouldHaveThePacketCount(t *testing.T) {
 	assert.Equal(t, bytes.Contains(packet.Bytes(), buf.Bytes()), true)
 }
 
-func Server(done chan<- int, port int, t *testing.T) {
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary failure" }
+func (temporaryError) Temporary() bool { return true }
+func (temporaryError) Timeout() bool   { return false }
+
+// fakeConn is a net.Conn that fails the first failures writes with err and
+// records everything written after that.
+type fakeConn struct {
+	net.Conn
+	failures int
+	err      error
+	writes   [][]byte
+}
+
+func (c *fakeConn) Write(b []byte) (int, error) {
+	if c.failures > 0 {
+		c.failures--
+		return 0, c.err
+	}
+	c.writes = append(c.writes, append([]byte(nil), b...))
+	return len(b), nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error { return nil }
+func (c *fakeConn) Close() error                       { return nil }
+
+func Server(port int, t *testing.T) <-chan int {
 	laddr, err := net.ResolveUDPAddr("udp", ":"+strconv.Itoa(port))
 	if err != nil {
 		panic(err)
 	}
-	buffer := make([]byte, 1024)
-	for {
-		conn, err := net.ListenUDP("udp", laddr)
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		panic(err)
+	}
+
+	done := make(chan int)
+	go func() {
+		defer conn.Close()
+
+		buffer := make([]byte, 1024)
+		n, err := conn.Read(buffer)
 		if err != nil {
 			panic(err)
 		}
+		if string(buffer[:n]) != "Hello Graylog" {
+			t.Error("TestServer Error - String not Equal.")
+		}
+		done <- 0
+	}()
 
-		for {
-			n, err := conn.Read(buffer)
-			if err != nil {
-				panic(err)
-			}
-			conn.Close()
-			if string(buffer[:n]) != "Hello Graylog" {
-				t.Error("TestServer Error - String not Equal.")
-			}
-			done <- 0
-			return
+	return done
+}
+
+func UdpServer(port int) <-chan []byte {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		panic(err)
+	}
+
+	received := make(chan []byte, 1)
+	go func() {
+		defer conn.Close()
+
+		buffer := make([]byte, 65536)
+		n, err := conn.Read(buffer)
+		if err != nil {
+			panic(err)
 		}
+		received <- buffer[:n]
+	}()
+
+	return received
+}
+
+func TcpServer(port int) <-chan []byte {
+	l, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port))
+	if err != nil {
+		panic(err)
+	}
+
+	return serveOnce(l)
+}
 
-		conn.Close()
+func TlsServer(port int, config *tls.Config) <-chan []byte {
+	l, err := tls.Listen("tcp", "127.0.0.1:"+strconv.Itoa(port), config)
+	if err != nil {
+		panic(err)
 	}
+
+	return serveOnce(l)
+}
+
+func serveOnce(l net.Listener) <-chan []byte {
+	received := make(chan []byte, 1)
+	go func() {
+		defer l.Close()
+
+		conn, err := l.Accept()
+		if err != nil {
+			panic(err)
+		}
+		defer conn.Close()
+
+		b, _ := io.ReadAll(conn)
+		received <- b
+	}()
+
+	return received
 }
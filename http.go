@@ -0,0 +1,77 @@
+package gelf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// post sends one message to the GELF HTTP input. It is gzipped if
+// Compression is "gzip" and it reaches CompressionThreshold, and sent as is
+// otherwise, HTTP needs no chunking.
+func (g *Gelf) post(ctx context.Context, message []byte) error {
+	if _, ok := ctx.Deadline(); !ok && g.Config.WriteTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Config.WriteTimeout)
+		defer cancel()
+	}
+
+	body := message
+	gzipped := g.Config.Compression == "gzip" && g.compresses(message)
+	if gzipped {
+		compressed := getBuffer()
+		defer putBuffer(compressed)
+		g.compress(compressed, message)
+		body = compressed.Bytes()
+	}
+	g.tee(body)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.url(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := g.httpClient().Do(req)
+	if err != nil {
+		g.stats.sendErrors.Add(1)
+		return err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection can be reused
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		g.stats.sendErrors.Add(1)
+		return fmt.Errorf("Graylog responded with %s", resp.Status)
+	}
+
+	g.stats.bytesSent.Add(uint64(len(body)))
+	return nil
+}
+
+func (g *Gelf) url() string {
+	scheme := "http"
+	if g.Config.UseTLS {
+		scheme = "https"
+	}
+
+	return scheme + "://" + g.address() + "/gelf"
+}
+
+func (g *Gelf) httpClient() *http.Client {
+	if g.Config.HTTPClient != nil {
+		return g.Config.HTTPClient
+	}
+
+	return defaultHTTPClient
+}
+
+var defaultHTTPClient = &http.Client{
+	Timeout: defaultHTTPTimeout,
+}
@@ -0,0 +1,125 @@
+package gelf
+
+import (
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+type httpRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func HttpServer(status int) (*httptest.Server, <-chan httpRequest) {
+	received := make(chan httpRequest, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			body, _ = gzip.NewReader(r.Body)
+		}
+		b, _ := io.ReadAll(body)
+
+		if r.URL.Path == "/gelf" && r.Method == http.MethodPost {
+			received <- httpRequest{header: r.Header, body: b}
+		}
+		w.WriteHeader(status)
+	}))
+
+	return server, received
+}
+
+func httpGelf(server *httptest.Server, compression string) *Gelf {
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	portInt, _ := strconv.Atoi(port)
+
+	return New(Config{
+		GraylogHostname: host,
+		GraylogPort:     portInt,
+		Connection:      "http",
+		Compression:     compression,
+	})
+}
+
+func Test_Log_itShouldPostToTheHttpInput(t *testing.T) {
+	server, received := HttpServer(http.StatusAccepted)
+	defer server.Close()
+
+	g := httpGelf(server, "")
+	assert.Equal(t, nil, g.Log(validJson))
+
+	req := <-received
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, "", req.header.Get("Content-Encoding"))
+
+	res := g.ParseJson(string(req.body))
+	assert.Equal(t, "Hello From Golang! :)", res["short_message"])
+	assert.Equal(t, "localhost", res["host"])
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+}
+
+func Test_Log_itShouldGzipHttpRequests(t *testing.T) {
+	server, received := HttpServer(http.StatusAccepted)
+	defer server.Close()
+
+	g := httpGelf(server, "gzip")
+	assert.Equal(t, nil, g.Log(validJson))
+
+	req := <-received
+	assert.Equal(t, "gzip", req.header.Get("Content-Encoding"))
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(req.body))["short_message"])
+}
+
+func Test_Log_itShouldNotGzipHttpRequestsBelowTheThreshold(t *testing.T) {
+	server, received := HttpServer(http.StatusAccepted)
+	defer server.Close()
+
+	g := httpGelf(server, "gzip")
+	g.Config.CompressionThreshold = 1024
+	assert.Equal(t, nil, g.Log(validJson))
+
+	req := <-received
+	assert.Equal(t, "", req.header.Get("Content-Encoding"))
+	assert.Equal(t, "Hello From Golang! :)", g.ParseJson(string(req.body))["short_message"])
+}
+
+func Test_Log_itShouldReturnAnErrorForFailedHttpRequests(t *testing.T) {
+	server, _ := HttpServer(http.StatusBadRequest)
+	defer server.Close()
+
+	g := httpGelf(server, "")
+	err := g.Log(validJson)
+
+	assert.Equal(t, "Graylog responded with 400 Bad Request", err.Error())
+	assert.Equal(t, uint64(1), g.Stats().SendErrors)
+}
+
+func Test_Log_itShouldUseTheConfiguredHttpClient(t *testing.T) {
+	server, received := HttpServer(http.StatusAccepted)
+	defer server.Close()
+
+	used := false
+	g := httpGelf(server, "")
+	g.Config.HTTPClient = &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			used = true
+			return http.DefaultTransport.RoundTrip(r)
+		}),
+	}
+	g.Log(validJson)
+
+	<-received
+	assert.Equal(t, true, used)
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
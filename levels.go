@@ -0,0 +1,161 @@
+package gelf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Syslog severities used for the GELF level field.
+const (
+	LevelEmergency = iota
+	LevelAlert
+	LevelCritical
+	LevelError
+	LevelWarning
+	LevelNotice
+	LevelInfo
+	LevelDebug
+)
+
+var levelNames = map[string]int{
+	"emergency": LevelEmergency,
+	"emerg":     LevelEmergency,
+	"alert":     LevelAlert,
+	"critical":  LevelCritical,
+	"crit":      LevelCritical,
+	"error":     LevelError,
+	"err":       LevelError,
+	"warning":   LevelWarning,
+	"warn":      LevelWarning,
+	"notice":    LevelNotice,
+	"info":      LevelInfo,
+	"debug":     LevelDebug,
+}
+
+// LevelFromString returns the level for a name like "warning" or its syslog
+// abbreviation "warn", ignoring case, e.g. for levels set in the
+// environment. Unknown names return LevelInfo and false.
+func LevelFromString(name string) (int, bool) {
+	level, ok := levelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return LevelInfo, false
+	}
+
+	return level, true
+}
+
+// LogLevel sends message at the level named by name, see LevelFromString.
+func (g *Gelf) LogLevel(name, message string) error {
+	level, _ := LevelFromString(name)
+
+	return g.logLevel(level, message)
+}
+
+func (g *Gelf) Emergency(message string) error {
+	return g.logLevel(LevelEmergency, message)
+}
+
+func (g *Gelf) Alert(message string) error {
+	return g.logLevel(LevelAlert, message)
+}
+
+func (g *Gelf) Critical(message string) error {
+	return g.logLevel(LevelCritical, message)
+}
+
+func (g *Gelf) Error(message string) error {
+	return g.logLevel(LevelError, message)
+}
+
+func (g *Gelf) Warning(message string) error {
+	return g.logLevel(LevelWarning, message)
+}
+
+func (g *Gelf) Notice(message string) error {
+	return g.logLevel(LevelNotice, message)
+}
+
+func (g *Gelf) Info(message string) error {
+	return g.logLevel(LevelInfo, message)
+}
+
+func (g *Gelf) Debug(message string) error {
+	return g.logLevel(LevelDebug, message)
+}
+
+// Logf formats a message like fmt.Sprintf and sends it at the default
+// level.
+func (g *Gelf) Logf(format string, args ...interface{}) error {
+	return g.logLevelf(defaultLevel, format, args)
+}
+
+func (g *Gelf) Emergencyf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelEmergency, format, args)
+}
+
+func (g *Gelf) Alertf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelAlert, format, args)
+}
+
+func (g *Gelf) Criticalf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelCritical, format, args)
+}
+
+func (g *Gelf) Errorf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelError, format, args)
+}
+
+func (g *Gelf) Warningf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelWarning, format, args)
+}
+
+func (g *Gelf) Noticef(format string, args ...interface{}) error {
+	return g.logLevelf(LevelNotice, format, args)
+}
+
+func (g *Gelf) Infof(format string, args ...interface{}) error {
+	return g.logLevelf(LevelInfo, format, args)
+}
+
+func (g *Gelf) Debugf(format string, args ...interface{}) error {
+	return g.logLevelf(LevelDebug, format, args)
+}
+
+// Enabled reports whether messages of level are sent under MinLevel. Without
+// MinLevel every level is.
+func (g *Gelf) Enabled(level int) bool {
+	return g.Config.MinLevel == nil || level <= *g.Config.MinLevel
+}
+
+func (g *Gelf) logLevel(level int, message string) error {
+	if !g.Enabled(level) {
+		return nil
+	}
+
+	gmap := g.message(message)
+	gmap["level"] = level
+
+	return g.log(gmap)
+}
+
+// logLevelf only formats messages that pass MinLevel.
+func (g *Gelf) logLevelf(level int, format string, args []interface{}) error {
+	if !g.Enabled(level) {
+		return nil
+	}
+
+	return g.logLevel(level, fmt.Sprintf(format, args...))
+}
+
+// intLevel returns the level field of a message, which is a float64 if it
+// was parsed from JSON.
+func intLevel(v interface{}) (int, bool) {
+	switch level := v.(type) {
+	case int:
+		return level, true
+	case float64:
+		return int(level), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,196 @@
+package gelf
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Levels_itShouldSendTheMatchingSeverity(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55570,
+		Compression: "none",
+	})
+
+	levels := map[int]func(string) error{
+		LevelEmergency: g.Emergency,
+		LevelAlert:     g.Alert,
+		LevelCritical:  g.Critical,
+		LevelError:     g.Error,
+		LevelWarning:   g.Warning,
+		LevelNotice:    g.Notice,
+		LevelInfo:      g.Info,
+		LevelDebug:     g.Debug,
+	}
+
+	for level, logFn := range levels {
+		received := UdpServer(55570)
+		assert.Equal(t, nil, logFn("Hello Graylog"))
+
+		res := g.ParseJson(string(<-received))
+		assert.Equal(t, float64(level), res["level"])
+		assert.Equal(t, "Hello Graylog", res["short_message"])
+	}
+}
+
+func Test_MinLevel_itShouldNotSendMessagesBelowIt(t *testing.T) {
+	dials := 0
+	g := New(Config{
+		MinLevel: intPtr(LevelInfo),
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			return &fakeConn{}, nil
+		},
+	})
+
+	assert.Equal(t, nil, g.Debug("Hello Graylog"))
+	assert.Equal(t, nil, g.Log(`{"short_message": "Hello Graylog", "level": 7}`))
+
+	assert.Equal(t, 0, dials)
+	assert.Equal(t, uint64(0), g.Stats().MessagesSent)
+}
+
+func Test_MinLevel_itShouldSendMessagesAtOrAboveIt(t *testing.T) {
+	g := New(Config{
+		Compression: "none",
+		MinLevel:    intPtr(LevelInfo),
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return &fakeConn{}, nil
+		},
+	})
+
+	g.Info("Hello Graylog")
+	g.Error("Hello Graylog")
+
+	assert.Equal(t, uint64(2), g.Stats().MessagesSent)
+}
+
+func Test_MinLevel_itShouldSendEverythingByDefault(t *testing.T) {
+	g := New(Config{})
+
+	assert.Equal(t, true, g.Enabled(LevelDebug))
+}
+
+func Test_MinLevel_itShouldOnlySendEmergenciesAtLevelEmergency(t *testing.T) {
+	g := New(Config{
+		Compression: "none",
+		MinLevel:    intPtr(LevelEmergency),
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return &fakeConn{}, nil
+		},
+	})
+
+	assert.Equal(t, nil, g.Alert("Hello Graylog"))
+	assert.Equal(t, uint64(0), g.Stats().MessagesSent)
+
+	assert.Equal(t, nil, g.Emergency("Hello Graylog"))
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+}
+
+func Test_MinLevel_itShouldHonorLevelEmergencyFromLevelFromString(t *testing.T) {
+	minLevel, ok := LevelFromString("emergency")
+	g := New(Config{MinLevel: &minLevel})
+
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, g.Enabled(LevelEmergency))
+	assert.Equal(t, false, g.Enabled(LevelAlert))
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func Test_Logf_itShouldFormatTheMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55619,
+		Compression: "none",
+	})
+
+	received := UdpServer(55619)
+	assert.Equal(t, nil, g.Logf("Hello %s, %d times", "Graylog", 3))
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog, 3 times", res["short_message"])
+	assert.Equal(t, float64(LevelInfo), res["level"])
+}
+
+func Test_Errorf_itShouldSendAtLevelError(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55620,
+		Compression: "none",
+	})
+
+	received := UdpServer(55620)
+	assert.Equal(t, nil, g.Errorf("request %d failed", 42))
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "request 42 failed", res["short_message"])
+	assert.Equal(t, float64(LevelError), res["level"])
+}
+
+// formatCounter counts how often it is formatted.
+type formatCounter int
+
+func (c *formatCounter) String() string {
+	*c++
+	return "counted"
+}
+
+func Test_Debugf_itShouldNotFormatFilteredMessages(t *testing.T) {
+	g := New(Config{
+		MinLevel: intPtr(LevelInfo),
+	})
+
+	var c formatCounter
+	assert.Equal(t, nil, g.Debugf("Hello %s", &c))
+	assert.Equal(t, formatCounter(0), c)
+}
+
+func Test_LevelFromString_itShouldMapTheNames(t *testing.T) {
+	names := map[string]int{
+		"emergency": LevelEmergency,
+		"alert":     LevelAlert,
+		"critical":  LevelCritical,
+		"error":     LevelError,
+		"warning":   LevelWarning,
+		"notice":    LevelNotice,
+		"info":      LevelInfo,
+		"debug":     LevelDebug,
+		"emerg":     LevelEmergency,
+		"crit":      LevelCritical,
+		"err":       LevelError,
+		"warn":      LevelWarning,
+		" WARNING ": LevelWarning,
+	}
+
+	for name, expected := range names {
+		level, ok := LevelFromString(name)
+		assert.Equal(t, true, ok)
+		assert.Equal(t, expected, level)
+	}
+}
+
+func Test_LevelFromString_itShouldDefaultToInfo(t *testing.T) {
+	level, ok := LevelFromString("verbose")
+
+	assert.Equal(t, false, ok)
+	assert.Equal(t, LevelInfo, level)
+}
+
+func Test_LogLevel_itShouldSendTheNamedLevel(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.LogLevel("warning", "Hello Graylog")
+	g.LogLevel("verbose", "Hello Graylog")
+
+	assert.Equal(t, float64(LevelWarning), g.ParseJson(string(conn.writes[0]))["level"])
+	assert.Equal(t, float64(LevelInfo), g.ParseJson(string(conn.writes[1]))["level"])
+}
@@ -0,0 +1,55 @@
+package gelf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LogError sends msg at LevelError with err as _error. For a wrapped error
+// _error_chain lists the messages of the whole chain, outermost first. If
+// an error in the chain formats itself with %+v, like those of
+// github.com/pkg/errors carrying a stack trace, that goes to full_message.
+func (g *Gelf) LogError(err error, msg string, fields map[string]interface{}) error {
+	if !g.Enabled(LevelError) {
+		return nil
+	}
+
+	gmap, ferr := g.fieldsMessage(msg, fields)
+	if ferr != nil {
+		return ferr
+	}
+	gmap["level"] = LevelError
+
+	if err != nil {
+		gmap["_error"] = err.Error()
+
+		chain := []string{err.Error()}
+		for e := errors.Unwrap(err); e != nil; e = errors.Unwrap(e) {
+			chain = append(chain, e.Error())
+		}
+		if len(chain) > 1 {
+			gmap["_error_chain"] = strings.Join(chain, " | ")
+		}
+
+		if _, ok := gmap["full_message"]; !ok {
+			if stack, ok := errorStack(err); ok {
+				gmap["full_message"] = stack
+			}
+		}
+	}
+
+	return g.log(gmap)
+}
+
+// errorStack returns the %+v output of the outermost error in the chain
+// implementing fmt.Formatter.
+func errorStack(err error) (string, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if _, ok := e.(fmt.Formatter); ok {
+			return fmt.Sprintf("%+v", e), true
+		}
+	}
+
+	return "", false
+}
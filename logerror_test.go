@@ -0,0 +1,70 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+// stackError formats like the errors of github.com/pkg/errors.
+type stackError struct {
+	error
+}
+
+func (e stackError) Format(s fmt.State, verb rune) {
+	io.WriteString(s, e.Error())
+	if s.Flag('+') {
+		io.WriteString(s, "\nmain.load\n\t/app/main.go:42")
+	}
+}
+
+func errorGelf(conn net.Conn) *Gelf {
+	return New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+}
+
+func Test_LogError_itShouldAddTheErrorChain(t *testing.T) {
+	conn := &fakeConn{}
+	g := errorGelf(conn)
+	err := fmt.Errorf("load config: %w", fmt.Errorf("open app.yml: %w", io.ErrUnexpectedEOF))
+
+	assert.Equal(t, nil, g.LogError(err, "Startup failed", map[string]interface{}{"service": "billing"}))
+
+	res := g.ParseJson(string(conn.writes[0]))
+	assert.Equal(t, "Startup failed", res["short_message"])
+	assert.Equal(t, float64(LevelError), res["level"])
+	assert.Equal(t, "billing", res["_service"])
+	assert.Equal(t, "load config: open app.yml: unexpected EOF", res["_error"])
+	assert.Equal(t, "load config: open app.yml: unexpected EOF | open app.yml: unexpected EOF | unexpected EOF", res["_error_chain"])
+	assert.Equal(t, nil, res["full_message"])
+}
+
+func Test_LogError_itShouldNotAddAChainForPlainErrors(t *testing.T) {
+	conn := &fakeConn{}
+	g := errorGelf(conn)
+
+	g.LogError(errors.New("boom"), "Request failed", nil)
+
+	res := g.ParseJson(string(conn.writes[0]))
+	assert.Equal(t, "boom", res["_error"])
+	assert.Equal(t, nil, res["_error_chain"])
+}
+
+func Test_LogError_itShouldSendTheStackTrace(t *testing.T) {
+	conn := &fakeConn{}
+	g := errorGelf(conn)
+
+	g.LogError(fmt.Errorf("request: %w", stackError{errors.New("boom")}), "Request failed", nil)
+
+	res := g.ParseJson(string(conn.writes[0]))
+	assert.Equal(t, "boom\nmain.load\n\t/app/main.go:42", res["full_message"])
+}
@@ -0,0 +1,61 @@
+// Package logrushook sends logrus entries to Graylog. Add the hook to an
+// existing logrus logger; its output to the terminal stays as it is.
+package logrushook
+
+import (
+	"time"
+
+	"github.com/robertkowalski/graylog-golang"
+	"github.com/sirupsen/logrus"
+)
+
+type hook struct {
+	gelf *gelf.Gelf
+}
+
+// NewLogrusHook returns a hook sending entries of all levels through g.
+func NewLogrusHook(g *gelf.Gelf) logrus.Hook {
+	return &hook{gelf: g}
+}
+
+func (h *hook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *hook) Fire(entry *logrus.Entry) error {
+	return h.gelf.LogJSON(gelfMessage(h.gelf, entry))
+}
+
+func gelfMessage(g *gelf.Gelf, entry *logrus.Entry) map[string]interface{} {
+	gmap := map[string]interface{}{
+		"short_message": entry.Message,
+		"level":         level(entry.Level),
+		"timestamp":     float64(entry.Time.UnixNano()) / float64(time.Second),
+	}
+
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		gmap[g.FieldName(k)] = v
+	}
+
+	return gmap
+}
+
+func level(l logrus.Level) int {
+	switch l {
+	case logrus.PanicLevel:
+		return gelf.LevelAlert
+	case logrus.FatalLevel:
+		return gelf.LevelCritical
+	case logrus.ErrorLevel:
+		return gelf.LevelError
+	case logrus.WarnLevel:
+		return gelf.LevelWarning
+	case logrus.InfoLevel:
+		return gelf.LevelInfo
+	default:
+		return gelf.LevelDebug
+	}
+}
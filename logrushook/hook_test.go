@@ -0,0 +1,76 @@
+package logrushook
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/robertkowalski/graylog-golang"
+	"github.com/robertkowalski/graylog-golang/testutil"
+	"github.com/sirupsen/logrus"
+)
+
+func Test_gelfMessage_itShouldMapTheEntryToGelf(t *testing.T) {
+	entry := &logrus.Entry{
+		Message: "Hello Graylog",
+		Level:   logrus.WarnLevel,
+		Time:    time.Unix(1356262644, 500000000),
+		Data: logrus.Fields{
+			"user":  "robert",
+			"error": errors.New("boom"),
+		},
+	}
+
+	res := gelfMessage(gelf.New(gelf.Config{}), entry)
+
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, gelf.LevelWarning, res["level"])
+	assert.Equal(t, 1356262644.5, res["timestamp"])
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, "boom", res["_error"])
+}
+
+func Test_gelfMessage_itShouldUseTheFieldPrefix(t *testing.T) {
+	prefix := "_app_"
+	g := gelf.New(gelf.Config{FieldPrefix: &prefix})
+	entry := &logrus.Entry{Data: logrus.Fields{"user": "robert", "_service": "billing"}}
+
+	res := gelfMessage(g, entry)
+
+	assert.Equal(t, "robert", res["_app_user"])
+	assert.Equal(t, "billing", res["_app_service"])
+}
+
+func Test_level_itShouldMapLogrusLevels(t *testing.T) {
+	assert.Equal(t, gelf.LevelAlert, level(logrus.PanicLevel))
+	assert.Equal(t, gelf.LevelCritical, level(logrus.FatalLevel))
+	assert.Equal(t, gelf.LevelError, level(logrus.ErrorLevel))
+	assert.Equal(t, gelf.LevelWarning, level(logrus.WarnLevel))
+	assert.Equal(t, gelf.LevelInfo, level(logrus.InfoLevel))
+	assert.Equal(t, gelf.LevelDebug, level(logrus.DebugLevel))
+	assert.Equal(t, gelf.LevelDebug, level(logrus.TraceLevel))
+}
+
+func Test_NewLogrusHook_itShouldFireForAllLevels(t *testing.T) {
+	h := NewLogrusHook(gelf.New(gelf.Config{}))
+
+	assert.Equal(t, logrus.AllLevels, h.Levels())
+}
+
+func Test_NewLogrusHook_itShouldAttachTheCallSite(t *testing.T) {
+	g, received := testutil.StartReceiverConfig(t, gelf.Config{IncludeCaller: true})
+	logger := logrus.New()
+	logger.Out = io.Discard
+	logger.AddHook(NewLogrusHook(g))
+
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("Hello Graylog")
+
+	res := <-received
+	assert.Equal(t, "hook_test.go", filepath.Base(res["_file"].(string)))
+	assert.Equal(t, float64(line+1), res["_line"])
+}
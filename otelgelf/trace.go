@@ -0,0 +1,24 @@
+// Package otelgelf correlates messages with OpenTelemetry traces, so that
+// Graylog can link from a message to the trace of the request it belongs to.
+package otelgelf
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TraceFields returns the trace and span ID of the span in ctx, or nil if
+// there is none. Use it as Config.ContextFields to add _trace_id and
+// _span_id to messages sent with LogContext.
+func TraceFields(ctx context.Context) map[string]interface{} {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"trace_id": sc.TraceID().String(),
+		"span_id":  sc.SpanID().String(),
+	}
+}
@@ -0,0 +1,47 @@
+package otelgelf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bmizerany/assert"
+	"github.com/robertkowalski/graylog-golang"
+	"github.com/robertkowalski/graylog-golang/testutil"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func spanContext() context.Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36},
+		SpanID:     trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	return trace.ContextWithSpanContext(context.Background(), sc)
+}
+
+func Test_TraceFields_itShouldReturnTheIDsOfTheSpan(t *testing.T) {
+	assert.Equal(t, map[string]interface{}{
+		"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":  "00f067aa0ba902b7",
+	}, TraceFields(spanContext()))
+}
+
+func Test_TraceFields_itShouldReturnNilWithoutASpan(t *testing.T) {
+	assert.Equal(t, map[string]interface{}(nil), TraceFields(context.Background()))
+}
+
+func Test_TraceFields_itShouldAddTheIDsInLogContext(t *testing.T) {
+	g, received := testutil.StartReceiverConfig(t, gelf.Config{ContextFields: TraceFields})
+
+	assert.Equal(t, nil, g.LogContext(spanContext(), "Hello Graylog"))
+	assert.Equal(t, nil, g.LogContext(context.Background(), "Hello Graylog"))
+
+	res := <-received
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", res["_trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", res["_span_id"])
+
+	res = <-received
+	assert.Equal(t, nil, res["_trace_id"])
+	assert.Equal(t, nil, res["_span_id"])
+}
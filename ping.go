@@ -0,0 +1,43 @@
+package gelf
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Ping checks that Graylog can be reached. Over TCP it dials a new
+// connection and closes it again. UDP cannot confirm receipt, so for
+// datagram connections Ping sends a debug message with the field _ping and
+// only reports whether it could be written. Over HTTP the same message is
+// posted. After Close it returns ErrClosed.
+func (g *Gelf) Ping() error {
+	g = g.base()
+	ctx := context.Background()
+	if g.closed.Load() {
+		return ErrClosed
+	}
+
+	if g.targets != nil {
+		return g.fanout(true, (*Gelf).Ping)
+	}
+
+	if g.network() == "tcp" {
+		conn, err := g.connect(ctx)
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	}
+
+	gmap := g.message("ping")
+	gmap["level"] = LevelDebug
+	gmap["_ping"] = true
+
+	msg, err := json.Marshal(gmap)
+	if err != nil {
+		return err
+	}
+
+	return g.transmit(ctx, msg)
+}
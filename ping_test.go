@@ -0,0 +1,77 @@
+package gelf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/lintianzhi/graylogd"
+)
+
+func Test_Ping_itShouldSendAMessageOverUDP(t *testing.T) {
+	waitChan := make(chan []byte, 1)
+	logd, err := graylogd.NewGraylogd(graylogd.Config{
+		ListenAddr: "127.0.0.1:2214",
+		HandleRaw: func(b []byte) {
+			waitChan <- b
+		},
+		HandleError: func(addr *net.UDPAddr, err error) {
+			t.Fatal("should be no error", err)
+		},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, nil, logd.Run())
+	defer logd.Close()
+
+	g := New(Config{
+		GraylogPort: 2214,
+	})
+	assert.Equal(t, nil, g.Ping())
+
+	select {
+	case b := <-waitChan:
+		assert.Equal(t, true, g.ParseJson(string(b))["_ping"])
+	case <-time.After(time.Second):
+		t.Fatal("ping is not received")
+	}
+}
+
+func Test_Ping_itShouldConnectOverTCP(t *testing.T) {
+	received := TcpServer(55614)
+
+	g := New(Config{
+		GraylogPort: 55614,
+		Connection:  "tcp",
+	})
+
+	assert.Equal(t, nil, g.Ping())
+	assert.Equal(t, 0, len(<-received))
+}
+
+func Test_Ping_itShouldFailIfTheTCPPortIsClosed(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55615,
+		Connection:  "tcp",
+	})
+
+	assert.NotEqual(t, nil, g.Ping())
+}
+
+func Test_Ping_itShouldNotDialAfterClose(t *testing.T) {
+	for _, connection := range []string{"udp", "tcp"} {
+		dials := 0
+		g := New(Config{
+			Connection: connection,
+			Dialer: func(ctx context.Context) (net.Conn, error) {
+				dials++
+				return &fakeConn{}, nil
+			},
+		})
+		assert.Equal(t, nil, g.Close())
+
+		assert.Equal(t, ErrClosed, g.Ping())
+		assert.Equal(t, 0, dials)
+	}
+}
@@ -0,0 +1,81 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"sync"
+)
+
+// maxPooledBuffer keeps the occasional huge message from pinning its buffer
+// in the pool.
+const maxPooledBuffer = 64 * 1024
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns buf to the pool. Nothing may hold on to its contents
+// afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBuffer {
+		return
+	}
+
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// compressor is a *zlib.Writer or *gzip.Writer.
+type compressor interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+// zlibWriters and gzipWriters pool compressors by level, from HuffmanOnly
+// (-2) to BestCompression (9). A new one allocates hundreds of KB of flate
+// state.
+var zlibWriters, gzipWriters [zlib.BestCompression - zlib.HuffmanOnly + 1]sync.Pool
+
+// getCompressor returns a compressor writing to w. Close it to flush
+// everything to w before handing it back with putCompressor.
+func getCompressor(gzipped bool, level int, w io.Writer) compressor {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		level = zlib.DefaultCompression
+	}
+
+	pool := &zlibWriters[level-zlib.HuffmanOnly]
+	if gzipped {
+		pool = &gzipWriters[level-zlib.HuffmanOnly]
+	}
+	if comp, ok := pool.Get().(compressor); ok {
+		comp.Reset(w)
+		return comp
+	}
+
+	if gzipped {
+		comp, _ := gzip.NewWriterLevel(w, level)
+		return comp
+	}
+	comp, _ := zlib.NewWriterLevel(w, level)
+	return comp
+}
+
+func putCompressor(gzipped bool, level int, comp compressor) {
+	if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+		level = zlib.DefaultCompression
+	}
+
+	if gzipped {
+		gzipWriters[level-zlib.HuffmanOnly].Put(comp)
+	} else {
+		zlibWriters[level-zlib.HuffmanOnly].Put(comp)
+	}
+}
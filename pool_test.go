@@ -0,0 +1,103 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func benchmarkAllocs(b *testing.B, config Config, message string) {
+	config.Connection = "discard"
+	g := New(config)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Log(message)
+	}
+}
+
+func Benchmark_AllocsShortMessage(b *testing.B) {
+	benchmarkAllocs(b, Config{Compression: "none"}, "Hello World")
+}
+
+func Benchmark_AllocsChunked(b *testing.B) {
+	benchmarkAllocs(b, Config{Compression: "none", MaxChunkSizeWan: 100}, strings.Repeat("Hello World", 100))
+}
+
+func Test_Log_itShouldNotReuseBuffersHandedToTheQueue(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Async:       true,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	for i := 0; i < 100; i++ {
+		g.Log("message " + strconv.Itoa(i))
+	}
+	g.Close()
+
+	assert.Equal(t, 100, len(conn.writes))
+	for i, b := range conn.writes {
+		assert.Equal(t, "message "+strconv.Itoa(i), g.ParseJson(string(b))["short_message"])
+	}
+}
+
+func Test_compress_itShouldResetPooledWriters(t *testing.T) {
+	for _, compression := range []string{"zlib", "gzip"} {
+		g := New(Config{Compression: compression, Connection: "discard"})
+
+		for i := 0; i < 10; i++ {
+			buf := new(bytes.Buffer)
+			g.compress(buf, []byte("message "+strconv.Itoa(i)))
+
+			var r io.Reader
+			if compression == "gzip" {
+				r, _ = gzip.NewReader(buf)
+			} else {
+				r, _ = zlib.NewReader(buf)
+			}
+			b, err := io.ReadAll(r)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "message "+strconv.Itoa(i), string(b))
+		}
+	}
+}
+
+func Benchmark_CompressPooledWriter(b *testing.B) {
+	g := New(Config{Connection: "discard"})
+	message := []byte(strings.Repeat("Hello World", 100))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		g.compress(buf, message)
+		putBuffer(buf)
+	}
+}
+
+func Benchmark_CompressNewWriter(b *testing.B) {
+	message := []byte(strings.Repeat("Hello World", 100))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := getBuffer()
+		comp, _ := zlib.NewWriterLevel(buf, zlib.DefaultCompression)
+		comp.Write(message)
+		comp.Close()
+		putBuffer(buf)
+	}
+}
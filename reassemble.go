@@ -0,0 +1,140 @@
+package gelf
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	defaultReassembleTimeout    = 5 * time.Second
+	defaultReassembleMaxPending = 1000
+)
+
+// Reassembler puts chunked GELF messages back together, e.g. in a receiver.
+// Chunks may arrive out of order, duplicates are ignored. Messages still
+// incomplete after Timeout, 5 seconds like in Graylog if zero, are
+// discarded. So are the oldest ones beyond MaxPending, 1000 if zero, so a
+// flood of first chunks can't use up memory. The zero value is ready to use
+// and it is safe for concurrent use.
+type Reassembler struct {
+	Timeout    time.Duration
+	MaxPending int
+
+	mu       sync.Mutex
+	messages map[string]*partialMessage
+	expired  uint64
+	now      func() time.Time
+}
+
+type partialMessage struct {
+	chunks [][]byte
+	// seen tells received chunks apart, an empty one is nil in chunks
+	seen     []bool
+	received int
+	first    time.Time
+}
+
+// Add takes one datagram. It returns the payload, still compressed, once all
+// chunks of its message arrived, or right away if datagram is not chunked.
+// An invalid chunk header is an error.
+func (r *Reassembler) Add(datagram []byte) (complete []byte, done bool, err error) {
+	id, seq, total, ok := ChunkHeader(datagram)
+	if !ok {
+		return datagram, true, nil
+	}
+	if total == 0 || int(total) > maxChunkCount || seq >= total {
+		return nil, false, fmt.Errorf("Chunk %d of %d is invalid", seq, total)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+	r.expire(now)
+
+	if r.messages == nil {
+		r.messages = make(map[string]*partialMessage)
+	}
+	key := string(id)
+	msg, ok := r.messages[key]
+	if !ok {
+		r.makeRoom()
+		msg = &partialMessage{chunks: make([][]byte, total), seen: make([]bool, total), first: now}
+		r.messages[key] = msg
+	}
+	if len(msg.chunks) != int(total) {
+		return nil, false, fmt.Errorf("Chunk %d of %d does not match the %d chunks of its message", seq, total, len(msg.chunks))
+	}
+	if msg.seen[seq] {
+		// a duplicate
+		return nil, false, nil
+	}
+
+	// datagram may be a reused read buffer
+	msg.chunks[seq] = append([]byte(nil), datagram[chunkHeaderSize:]...)
+	msg.seen[seq] = true
+	msg.received++
+	if msg.received < len(msg.chunks) {
+		return nil, false, nil
+	}
+
+	delete(r.messages, key)
+	return bytes.Join(msg.chunks, nil), true, nil
+}
+
+// Pending returns the number of incomplete messages.
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.messages)
+}
+
+// Expired returns the number of incomplete messages discarded after Timeout
+// or beyond MaxPending.
+func (r *Reassembler) Expired() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.expired
+}
+
+func (r *Reassembler) expire(now time.Time) {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = defaultReassembleTimeout
+	}
+
+	for key, msg := range r.messages {
+		if now.Sub(msg.first) >= timeout {
+			delete(r.messages, key)
+			r.expired++
+		}
+	}
+}
+
+// makeRoom discards the oldest incomplete messages until another one fits
+// under MaxPending.
+func (r *Reassembler) makeRoom() {
+	max := r.MaxPending
+	if max <= 0 {
+		max = defaultReassembleMaxPending
+	}
+
+	for len(r.messages) >= max {
+		var oldest string
+		var first time.Time
+		for key, msg := range r.messages {
+			if first.IsZero() || msg.first.Before(first) {
+				oldest, first = key, msg.first
+			}
+		}
+		delete(r.messages, oldest)
+		r.expired++
+	}
+}
@@ -0,0 +1,124 @@
+package gelf
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Reassembler_itShouldReassembleOutOfOrderChunks(t *testing.T) {
+	var r Reassembler
+	chunks := Chunks([]byte("Hello Graylog"), 5, []byte("abcdefgh"))
+
+	for _, i := range []int{2, 0} {
+		b, done, err := r.Add(chunks[i])
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, done)
+		assert.Equal(t, true, b == nil)
+	}
+	assert.Equal(t, 1, r.Pending())
+
+	b, done, err := r.Add(chunks[1])
+	assert.Equal(t, nil, err)
+	assert.Equal(t, true, done)
+	assert.Equal(t, "Hello Graylog", string(b))
+	assert.Equal(t, 0, r.Pending())
+}
+
+func Test_Reassembler_itShouldIgnoreDuplicateChunks(t *testing.T) {
+	var r Reassembler
+	chunks := Chunks([]byte("Hello Graylog"), 5, []byte("abcdefgh"))
+
+	r.Add(chunks[0])
+	r.Add(chunks[1])
+	_, done, err := r.Add(chunks[1])
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, done)
+
+	b, done, _ := r.Add(chunks[2])
+	assert.Equal(t, true, done)
+	assert.Equal(t, "Hello Graylog", string(b))
+}
+
+func Test_Reassembler_itShouldIgnoreDuplicateEmptyChunks(t *testing.T) {
+	var r Reassembler
+	chunks := Chunks([]byte("ab"), 1, []byte("abcdefgh"))
+	empty := chunks[0][:chunkHeaderSize]
+
+	r.Add(empty)
+	_, done, err := r.Add(empty)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, false, done)
+
+	b, done, _ := r.Add(chunks[1])
+	assert.Equal(t, true, done)
+	assert.Equal(t, "b", string(b))
+}
+
+func Test_Reassembler_itShouldDiscardTheOldestBeyondMaxPending(t *testing.T) {
+	now := time.Unix(1356262644, 0)
+	r := Reassembler{MaxPending: 2}
+	r.now = func() time.Time { return now }
+
+	first := Chunks([]byte("Hello Graylog"), 7, []byte("aaaaaaaa"))
+	for _, id := range []string{"aaaaaaaa", "bbbbbbbb", "cccccccc"} {
+		r.Add(Chunks([]byte("Hello Graylog"), 7, []byte(id))[0])
+		now = now.Add(time.Millisecond)
+	}
+	assert.Equal(t, 2, r.Pending())
+	assert.Equal(t, uint64(1), r.Expired())
+
+	_, done, _ := r.Add(first[1])
+	assert.Equal(t, false, done)
+}
+
+func Test_Reassembler_itShouldKeepMessagesApart(t *testing.T) {
+	var r Reassembler
+	first := Chunks([]byte("Hello Graylog"), 7, []byte("abcdefgh"))
+	second := Chunks([]byte("Hello again"), 7, []byte("12345678"))
+
+	r.Add(first[0])
+	r.Add(second[0])
+	b, _, _ := r.Add(second[1])
+	assert.Equal(t, "Hello again", string(b))
+	b, _, _ = r.Add(first[1])
+	assert.Equal(t, "Hello Graylog", string(b))
+}
+
+func Test_Reassembler_itShouldExpireIncompleteMessages(t *testing.T) {
+	now := time.Unix(1356262644, 0)
+	r := Reassembler{Timeout: time.Second}
+	r.now = func() time.Time { return now }
+
+	// the last chunk is dropped
+	dropped := Chunks([]byte(strings.Repeat("Hello Graylog", 3)), 10, []byte("abcdefgh"))
+	r.Add(dropped[0])
+	r.Add(dropped[1])
+	assert.Equal(t, 1, r.Pending())
+
+	now = now.Add(2 * time.Second)
+	b, done, _ := r.Add([]byte(`{"short_message":"Hello Graylog"}`))
+	assert.Equal(t, true, done)
+	assert.Equal(t, `{"short_message":"Hello Graylog"}`, string(b))
+
+	r.Add(Chunks([]byte("Hello Graylog"), 5, []byte("12345678"))[0])
+	assert.Equal(t, 1, r.Pending())
+	assert.Equal(t, uint64(1), r.Expired())
+
+	// a late chunk starts the message over
+	_, done, _ = r.Add(dropped[2])
+	assert.Equal(t, false, done)
+}
+
+func Test_Reassembler_itShouldRejectInvalidChunks(t *testing.T) {
+	var r Reassembler
+
+	_, _, err := r.Add([]byte("\x1e\x0fabcdefgh\x03\x03Hello"))
+	assert.Equal(t, "Chunk 3 of 3 is invalid", err.Error())
+
+	r.Add([]byte("\x1e\x0fabcdefgh\x00\x02Hello"))
+	_, _, err = r.Add([]byte("\x1e\x0fabcdefgh\x01\x03Hello"))
+	assert.NotEqual(t, nil, err)
+}
@@ -0,0 +1,155 @@
+package gelf
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// sendBuffered writes the messages kept from failed writes, then b. If a
+// write fails b is kept too, up to ReconnectBufferSize messages; beyond that
+// the oldest one is dropped. The next send after Graylog is back delivers
+// them in order, or the retrier once Graylog is back.
+func (g *Gelf) sendBuffered(ctx context.Context, b []byte) error {
+	err := g.flushRetry(ctx)
+	if err == nil {
+		err = g.writePacket(ctx, b)
+	}
+	if err != nil {
+		g.keep(b)
+	}
+
+	return err
+}
+
+// keep adds a copy of b to the reconnect buffer. b may be a pooled buffer.
+func (g *Gelf) keep(b []byte) {
+	if len(g.retry) >= g.Config.ReconnectBufferSize {
+		g.retry = g.retry[1:]
+		g.stats.messagesDropped.Add(1)
+	}
+
+	g.retry = append(g.retry, append([]byte(nil), b...))
+}
+
+// keepFrames keeps the null-terminated frames of a failed batch. They were
+// counted as sent when they were batched, which is undone here. A frame cut
+// off at the end of p cannot be sent again and is dropped.
+func (g *Gelf) keepFrames(p []byte) {
+	for _, frame := range bytes.SplitAfter(p, []byte{0}) {
+		if len(frame) == 0 {
+			continue
+		}
+		g.stats.messagesSent.Add(^uint64(0))
+		if frame[len(frame)-1] != 0 {
+			g.stats.messagesDropped.Add(1)
+			continue
+		}
+		g.keep(frame)
+	}
+}
+
+// flushRetry writes the buffered messages, stopping at the first failure.
+func (g *Gelf) flushRetry(ctx context.Context) error {
+	for len(g.retry) > 0 {
+		if err := g.writePacket(ctx, g.retry[0]); err != nil {
+			return err
+		}
+		g.retry = g.retry[1:]
+		g.stats.messagesSent.Add(1)
+	}
+	g.retry = nil
+
+	return nil
+}
+
+// dropRetry gives up on the buffered messages.
+func (g *Gelf) dropRetry() {
+	g.stats.messagesDropped.Add(uint64(len(g.retry)))
+	g.retry = nil
+}
+
+func (g *Gelf) startRetrier() {
+	g.retryStop = make(chan struct{})
+	g.retryDone = make(chan struct{})
+
+	go g.retryEvery(g.Config.ReconnectInterval)
+}
+
+// retryEvery writes the buffered messages periodically, so that they are
+// delivered once Graylog is back even if nothing else is logged.
+func (g *Gelf) retryEvery(interval time.Duration) {
+	defer close(g.retryDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.mu.Lock()
+			var err error
+			if len(g.retry) > 0 {
+				err = g.flushRetry(context.Background())
+			}
+			g.mu.Unlock()
+
+			if err != nil {
+				g.onError(err)
+			}
+		case <-g.retryStop:
+			return
+		}
+	}
+}
+
+func (g *Gelf) stopRetrier() {
+	if g.retryStop == nil {
+		return
+	}
+
+	close(g.retryStop)
+	<-g.retryDone
+}
+
+// Reconnect writes out batched frames, closes the connection and dials a new
+// one, then sends the messages kept by ReconnectBufferSize, resolving GraylogHostname again, e.g. on SIGHUP after the network
+// changed. Over HTTP it closes idle connections instead.
+func (g *Gelf) Reconnect() error {
+	if g.root != nil {
+		return g.root.Reconnect()
+	}
+	if g.closed.Load() {
+		return ErrClosed
+	}
+	if g.targets != nil {
+		return g.fanout(true, (*Gelf).Reconnect)
+	}
+
+	switch g.network() {
+	case "http":
+		g.httpClient().CloseIdleConnections()
+		return nil
+	case "transport":
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	err := g.flushBatch()
+	g.closeConn()
+	g.udpAddr = nil
+
+	conn, cerr := g.connect(context.Background())
+	if cerr != nil {
+		return cerr
+	}
+	g.conn = conn
+
+	if rerr := g.flushRetry(context.Background()); err == nil {
+		err = rerr
+	}
+
+	return err
+}
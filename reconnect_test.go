@@ -0,0 +1,178 @@
+package gelf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_ReconnectBufferSize_itShouldDeliverBufferedMessages(t *testing.T) {
+	g := New(Config{
+		GraylogPort:         55634,
+		GraylogHostname:     "127.0.0.1",
+		Connection:          "tcp",
+		ReconnectBufferSize: 2,
+	})
+
+	// nothing is listening, so these fail and the last two are kept
+	assert.NotEqual(t, nil, g.Log(`{"short_message":"first"}`))
+	assert.NotEqual(t, nil, g.Log(`{"short_message":"second"}`))
+	assert.NotEqual(t, nil, g.Log(`{"short_message":"third"}`))
+
+	received := TcpServer(55634)
+	assert.Equal(t, nil, g.Log(`{"short_message":"fourth"}`))
+	g.Close()
+
+	messages := bytes.Split(bytes.TrimSuffix(<-received, []byte{0}), []byte{0})
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, "second", g.ParseJson(string(messages[0]))["short_message"])
+	assert.Equal(t, "third", g.ParseJson(string(messages[1]))["short_message"])
+	assert.Equal(t, "fourth", g.ParseJson(string(messages[2]))["short_message"])
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(1), stats.MessagesDropped)
+	assert.Equal(t, uint64(3), stats.MessagesSent)
+}
+
+func Test_ReconnectBufferSize_itShouldDeliverBufferedMessagesOnFlush(t *testing.T) {
+	conn := &fakeConn{failures: 2, err: syscall.EPIPE}
+	g := New(Config{
+		Connection:          "tcp",
+		ReconnectBufferSize: 10,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	err := g.Log("Hello Graylog")
+	assert.Equal(t, true, errors.Is(err, syscall.EPIPE))
+	assert.Equal(t, 0, len(conn.writes))
+
+	assert.Equal(t, nil, g.Flush())
+	assert.Equal(t, 1, len(conn.writes))
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(bytes.TrimSuffix(conn.writes[0], []byte{0})))["short_message"])
+}
+
+func Test_ReconnectBufferSize_itShouldDeliverBufferedMessagesWhenGraylogIsBack(t *testing.T) {
+	conn := &fakeConn{failures: 2, err: syscall.EPIPE}
+	g := New(Config{
+		Connection:          "tcp",
+		ReconnectBufferSize: 10,
+		ReconnectInterval:   10 * time.Millisecond,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+	defer g.Close()
+
+	assert.NotEqual(t, nil, g.Log("Hello Graylog"))
+	time.Sleep(50 * time.Millisecond)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	assert.Equal(t, 1, len(conn.writes))
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+}
+
+func Test_ReconnectBufferSize_itShouldKeepFailedBatches(t *testing.T) {
+	conn := &fakeConn{failures: 2, err: syscall.EPIPE}
+	g := New(Config{
+		Connection:          "tcp",
+		BatchSize:           4096,
+		BatchInterval:       time.Hour,
+		ReconnectBufferSize: 10,
+		ReconnectInterval:   time.Hour,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+	defer g.Close()
+
+	g.Log("one")
+	g.Log("two")
+	// the batch fails, its frames are kept and sent again one by one
+	assert.NotEqual(t, nil, g.Flush())
+	assert.Equal(t, 2, len(conn.writes))
+	assert.Equal(t, uint64(2), g.Stats().MessagesSent)
+
+	g.Log("three")
+	assert.Equal(t, nil, g.Flush())
+
+	assert.Equal(t, 3, len(conn.writes))
+	assert.Equal(t, "one", g.ParseJson(string(bytes.TrimSuffix(conn.writes[0], []byte{0})))["short_message"])
+	assert.Equal(t, "two", g.ParseJson(string(bytes.TrimSuffix(conn.writes[1], []byte{0})))["short_message"])
+	assert.Equal(t, "three", g.ParseJson(string(bytes.TrimSuffix(conn.writes[2], []byte{0})))["short_message"])
+	assert.Equal(t, uint64(3), g.Stats().MessagesSent)
+}
+
+func Test_Reconnect_itShouldDeliverBufferedMessages(t *testing.T) {
+	conn := &fakeConn{failures: 2, err: syscall.EPIPE}
+	g := New(Config{
+		Connection:          "tcp",
+		ReconnectBufferSize: 10,
+		ReconnectInterval:   time.Hour,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+	defer g.Close()
+
+	assert.NotEqual(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, nil, g.Reconnect())
+
+	assert.Equal(t, 1, len(conn.writes))
+}
+
+func Test_Reconnect_itShouldUseANewConnection(t *testing.T) {
+	first, second := &fakeConn{}, &fakeConn{}
+	conns := []*fakeConn{first, second}
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			conn := conns[0]
+			conns = conns[1:]
+			return conn, nil
+		},
+	})
+
+	g.Log("before")
+	assert.Equal(t, nil, g.Reconnect())
+	assert.Equal(t, net.Conn(second), g.conn)
+	g.Log("after")
+
+	assert.Equal(t, 1, len(first.writes))
+	assert.Equal(t, 1, len(second.writes))
+	assert.Equal(t, "after", g.ParseJson(string(second.writes[0]))["short_message"])
+}
+
+func Test_Reconnect_itShouldResolveAgain(t *testing.T) {
+	g := New(Config{Compression: "none"})
+	port := 55640
+	g.resolve = func(network, address string) (*net.UDPAddr, error) {
+		return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, nil
+	}
+
+	received := UdpServer(55640)
+	g.Send([]byte("Hello Graylog"))
+	assert.Equal(t, []byte("Hello Graylog"), <-received)
+
+	port = 55641
+	assert.Equal(t, nil, g.Reconnect())
+
+	received = UdpServer(55641)
+	g.Send([]byte("Hello again"))
+	assert.Equal(t, []byte("Hello again"), <-received)
+}
+
+func Test_Reconnect_itShouldFailWhenClosed(t *testing.T) {
+	g := New(Config{Connection: "discard"})
+	g.Close()
+
+	assert.Equal(t, ErrClosed, g.Reconnect())
+}
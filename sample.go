@@ -0,0 +1,32 @@
+package gelf
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// sample reports whether a message is kept under SampleRate. With a
+// SampleKey, messages with the same key are always kept or dropped
+// together, otherwise every message is kept at random.
+func (g *Gelf) sample(gmap map[string]interface{}) bool {
+	if g.Config.SampleRate >= 1 {
+		return true
+	}
+
+	if g.Config.SampleKey == nil {
+		return rand.Float64() < g.Config.SampleRate
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(g.Config.SampleKey(fmt.Sprint(gmap["short_message"]))))
+
+	// FNV spreads similar keys poorly over the high bits, mix them first
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+
+	return float64(x) < g.Config.SampleRate*math.MaxUint64
+}
@@ -0,0 +1,73 @@
+package gelf
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func sampledGelf(config Config) *Gelf {
+	config.Compression = "none"
+	config.Dialer = func(ctx context.Context) (net.Conn, error) {
+		return &fakeConn{}, nil
+	}
+
+	return New(config)
+}
+
+func Test_SampleRate_itShouldKeepEverythingByDefault(t *testing.T) {
+	g := sampledGelf(Config{})
+
+	for i := 0; i < 100; i++ {
+		g.Log("Hello Graylog")
+	}
+
+	assert.Equal(t, uint64(100), g.Stats().MessagesSent)
+	assert.Equal(t, uint64(0), g.Stats().MessagesSampledOut)
+}
+
+func Test_SampleRate_itShouldKeepTheConfiguredFraction(t *testing.T) {
+	g := sampledGelf(Config{SampleRate: 0.1})
+
+	for i := 0; i < 10000; i++ {
+		assert.Equal(t, nil, g.Log("Hello Graylog"))
+	}
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(10000), stats.MessagesSent+stats.MessagesSampledOut)
+	if stats.MessagesSent < 800 || stats.MessagesSent > 1200 {
+		t.Errorf("kept %d of 10000 messages, expected about 1000", stats.MessagesSent)
+	}
+}
+
+func Test_SampleKey_itShouldDecideConsistentlyPerKey(t *testing.T) {
+	g := sampledGelf(Config{
+		SampleRate: 0.5,
+		SampleKey:  func(message string) string { return message },
+	})
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		key := "request " + strconv.Itoa(i)
+		before := g.Stats().MessagesSent
+		g.Log(key)
+		first := g.Stats().MessagesSent > before
+
+		for j := 0; j < 3; j++ {
+			before = g.Stats().MessagesSent
+			g.Log(key)
+			assert.Equal(t, first, g.Stats().MessagesSent > before)
+		}
+
+		if first {
+			kept++
+		}
+	}
+
+	if kept < 400 || kept > 600 {
+		t.Errorf("kept %d of 1000 keys, expected about 500", kept)
+	}
+}
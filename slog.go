@@ -0,0 +1,149 @@
+package gelf
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"time"
+)
+
+type slogHandler struct {
+	gelf   *Gelf
+	opts   slog.HandlerOptions
+	fields map[string]interface{}
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler that sends every record to Graylog.
+// Attributes become additional fields, with groups joined by dots. Of opts,
+// Level, AddSource (as _file and _line) and ReplaceAttr are supported.
+// ReplaceAttr is only called for attributes, the message, level and time go
+// to their GELF fields as they are.
+func NewSlogHandler(g *Gelf, opts *slog.HandlerOptions) slog.Handler {
+	h := &slogHandler{
+		gelf:   g,
+		fields: map[string]interface{}{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+
+	return h
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+
+	return level >= min && h.gelf.Enabled(slogLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	return h.gelf.log(h.gelfMessage(r))
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	child := h.clone()
+	for _, a := range attrs {
+		child.addAttr(child.fields, child.groups, a)
+	}
+
+	return child
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	child := h.clone()
+	child.groups = append(child.groups[:len(child.groups):len(child.groups)], name)
+
+	return child
+}
+
+func (h *slogHandler) clone() *slogHandler {
+	fields := make(map[string]interface{}, len(h.fields))
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+
+	return &slogHandler{
+		gelf:   h.gelf,
+		opts:   h.opts,
+		fields: fields,
+		groups: h.groups,
+	}
+}
+
+func (h *slogHandler) gelfMessage(r slog.Record) map[string]interface{} {
+	gmap := h.gelf.message(r.Message)
+	gmap["level"] = slogLevel(r.Level)
+	if !r.Time.IsZero() {
+		gmap["timestamp"] = float64(r.Time.UnixNano()) / float64(time.Second)
+	}
+
+	if h.opts.AddSource && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		gmap["_file"] = frame.File
+		gmap["_line"] = frame.Line
+	}
+
+	for k, v := range h.fields {
+		gmap[k] = v
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		h.addAttr(gmap, h.groups, a)
+		return true
+	})
+
+	return gmap
+}
+
+func (h *slogHandler) addAttr(gmap map[string]interface{}, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup && h.opts.ReplaceAttr != nil {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			h.addAttr(gmap, groups, ga)
+		}
+		return
+	}
+	name := h.gelf.FieldName(strings.Join(append(groups[:len(groups):len(groups)], a.Key), "."))
+	if name == "_id" {
+		// Graylog reserves _id, sending it would fail the whole record
+		name = "_id_"
+	}
+	gmap[name] = a.Value.Any()
+}
+
+// slogLevel maps a slog level to the matching syslog severity.
+func slogLevel(l slog.Level) int {
+	switch {
+	case l >= slog.LevelError:
+		return LevelError
+	case l >= slog.LevelWarn:
+		return LevelWarning
+	case l >= slog.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelDebug
+	}
+}
@@ -0,0 +1,150 @@
+package gelf
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_NewSlogHandler_itShouldMapTheRecordToGelf(t *testing.T) {
+	h := NewSlogHandler(New(Config{}), nil).(*slogHandler)
+	now := time.Unix(1356262644, 500000000)
+	r := slog.NewRecord(now, slog.LevelWarn, "Hello Graylog", 0)
+	r.AddAttrs(slog.String("user", "robert"), slog.Int("status", 200))
+
+	res := h.gelfMessage(r)
+
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, 4, res["level"])
+	assert.Equal(t, 1356262644.5, res["timestamp"])
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, int64(200), res["_status"])
+}
+
+func Test_NewSlogHandler_itShouldSendErrorMessages(t *testing.T) {
+	g := New(Config{Compression: "none"})
+	h := NewSlogHandler(g, nil).(*slogHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelError, "Hello Graylog", 0)
+	r.AddAttrs(slog.Any("err", errors.New("connection refused")))
+
+	b, err := g.build(h.gelfMessage(r))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "connection refused", g.ParseJson(string(b))["_err"])
+}
+
+func Test_NewSlogHandler_itShouldUseTheFieldPrefix(t *testing.T) {
+	h := NewSlogHandler(New(Config{FieldPrefix: strPtr("_app_")}), nil).WithGroup("request").(*slogHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", 0)
+	r.AddAttrs(slog.String("user", "robert"))
+
+	assert.Equal(t, "robert", h.gelfMessage(r)["_app_request.user"])
+
+	h = NewSlogHandler(New(Config{}), nil).(*slogHandler)
+	r = slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", 0)
+	r.AddAttrs(slog.String("_user", "robert"))
+
+	res := h.gelfMessage(r)
+	assert.Equal(t, "robert", res["_user"])
+	assert.Equal(t, nil, res["__user"])
+}
+
+func Test_NewSlogHandler_itShouldAccumulateAttrsAndGroups(t *testing.T) {
+	h := NewSlogHandler(New(Config{}), nil).
+		WithAttrs([]slog.Attr{slog.String("service", "api")}).
+		WithGroup("request").
+		WithAttrs([]slog.Attr{slog.String("id", "42")}).(*slogHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", 0)
+	r.AddAttrs(slog.Group("http", slog.String("method", "GET")))
+
+	res := h.gelfMessage(r)
+
+	assert.Equal(t, "api", res["_service"])
+	assert.Equal(t, "42", res["_request.id"])
+	assert.Equal(t, "GET", res["_request.http.method"])
+}
+
+func Test_NewSlogHandler_itShouldRespectTheMinimumLevel(t *testing.T) {
+	h := NewSlogHandler(New(Config{}), &slog.HandlerOptions{Level: slog.LevelWarn})
+
+	assert.Equal(t, false, h.Enabled(context.Background(), slog.LevelInfo))
+	assert.Equal(t, true, h.Enabled(context.Background(), slog.LevelError))
+}
+
+func Test_NewSlogHandler_itShouldSendRecords(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55567,
+		Compression: "none",
+	})
+
+	received := UdpServer(55567)
+	slog.New(NewSlogHandler(g, nil)).Error("Hello Graylog", "user", "robert")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, float64(3), res["level"])
+	assert.Equal(t, "robert", res["_user"])
+}
+
+func Test_NewSlogHandler_itShouldRenameTheReservedId(t *testing.T) {
+	g := New(Config{Compression: "none"})
+	h := NewSlogHandler(g, nil).(*slogHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", 0)
+	r.AddAttrs(slog.Int("id", 42))
+
+	b, err := g.build(h.gelfMessage(r))
+	assert.Equal(t, nil, err)
+	res := g.ParseJson(string(b))
+	assert.Equal(t, float64(42), res["_id_"])
+	assert.Equal(t, nil, res["_id"])
+}
+
+func Test_slogLevel_itShouldMapToSyslogSeverities(t *testing.T) {
+	assert.Equal(t, 7, slogLevel(slog.LevelDebug))
+	assert.Equal(t, 6, slogLevel(slog.LevelInfo))
+	assert.Equal(t, 4, slogLevel(slog.LevelWarn))
+	assert.Equal(t, 3, slogLevel(slog.LevelError))
+}
+
+func Test_NewSlogHandler_itShouldAddTheSource(t *testing.T) {
+	h := NewSlogHandler(New(Config{}), &slog.HandlerOptions{AddSource: true}).(*slogHandler)
+	var pcs [1]uintptr
+	runtime.Callers(1, pcs[:])
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", pcs[0])
+
+	res := h.gelfMessage(r)
+
+	assert.Equal(t, true, strings.HasSuffix(res["_file"].(string), "slog_test.go"))
+	assert.NotEqual(t, 0, res["_line"])
+}
+
+func Test_NewSlogHandler_itShouldReplaceAttrs(t *testing.T) {
+	var seen [][]string
+	h := NewSlogHandler(New(Config{}), &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			seen = append(seen, groups)
+			switch a.Key {
+			case "password":
+				return slog.Attr{}
+			case "user":
+				return slog.String("user", strings.ToUpper(a.Value.String()))
+			}
+			return a
+		},
+	}).WithAttrs([]slog.Attr{slog.String("password", "secret")}).WithGroup("request").(*slogHandler)
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "Hello Graylog", 0)
+	r.AddAttrs(slog.String("user", "robert"), slog.Group("http", slog.String("method", "GET")))
+
+	res := h.gelfMessage(r)
+
+	_, ok := res["_password"]
+	assert.Equal(t, false, ok)
+	assert.Equal(t, "ROBERT", res["_request.user"])
+	assert.Equal(t, "GET", res["_request.http.method"])
+	assert.Equal(t, [][]string{nil, {"request"}, {"request", "http"}}, seen)
+}
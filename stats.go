@@ -0,0 +1,52 @@
+package gelf
+
+import "sync/atomic"
+
+// Stats is a snapshot of the counters of a Gelf. MessagesDropped only
+// grows with Async and a dropping OverflowPolicy, MessagesSampledOut only
+// with a SampleRate below 1.
+type Stats struct {
+	MessagesSent       uint64
+	ChunksSent         uint64
+	BytesSent          uint64
+	SendErrors         uint64
+	MessagesDropped    uint64
+	MessagesSampledOut uint64
+}
+
+type counters struct {
+	messagesSent       atomic.Uint64
+	chunksSent         atomic.Uint64
+	bytesSent          atomic.Uint64
+	sendErrors         atomic.Uint64
+	messagesDropped    atomic.Uint64
+	messagesSampledOut atomic.Uint64
+}
+
+// Stats returns a snapshot of the counters.
+func (g *Gelf) Stats() Stats {
+	if g.root != nil {
+		return g.root.Stats()
+	}
+
+	stats := Stats{
+		MessagesSent:       g.stats.messagesSent.Load(),
+		ChunksSent:         g.stats.chunksSent.Load(),
+		BytesSent:          g.stats.bytesSent.Load(),
+		SendErrors:         g.stats.sendErrors.Load(),
+		MessagesDropped:    g.stats.messagesDropped.Load(),
+		MessagesSampledOut: g.stats.messagesSampledOut.Load(),
+	}
+
+	// messages are only sampled out before they reach the targets
+	for _, t := range g.targets {
+		ts := t.Stats()
+		stats.MessagesSent += ts.MessagesSent
+		stats.ChunksSent += ts.ChunksSent
+		stats.BytesSent += ts.BytesSent
+		stats.SendErrors += ts.SendErrors
+		stats.MessagesDropped += ts.MessagesDropped
+	}
+
+	return stats
+}
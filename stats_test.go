@@ -0,0 +1,54 @@
+package gelf
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Stats_itShouldCountSentMessages(t *testing.T) {
+	g := New(Config{
+		Compression: "none",
+	})
+	conn := &fakeConn{}
+	g.conn = conn
+
+	g.Log("Hello Graylog")
+	g.Log("Hello again")
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(2), stats.MessagesSent)
+	assert.Equal(t, uint64(0), stats.ChunksSent)
+	assert.Equal(t, uint64(len(conn.writes[0])+len(conn.writes[1])), stats.BytesSent)
+	assert.Equal(t, uint64(0), stats.SendErrors)
+}
+
+func Test_Stats_itShouldCountChunks(t *testing.T) {
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 50,
+	})
+	conn := &fakeConn{}
+	g.conn = conn
+
+	g.Log("Hello Graylog")
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(1), stats.MessagesSent)
+	assert.Equal(t, uint64(len(conn.writes)), stats.ChunksSent)
+	assert.NotEqual(t, uint64(0), stats.ChunksSent)
+}
+
+func Test_Stats_itShouldCountSendErrors(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55590,
+		GraylogHostname: "127.0.0.1",
+		Connection:      "tcp",
+	})
+
+	g.Log("Hello Graylog")
+
+	stats := g.Stats()
+	assert.Equal(t, uint64(0), stats.MessagesSent)
+	assert.Equal(t, uint64(1), stats.SendErrors)
+}
@@ -0,0 +1,101 @@
+package gelf
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// validates reports whether field names are checked, see SkipValidation.
+// Strict overrides it.
+func (g *Gelf) validates() bool {
+	return g.Config.Strict || !g.Config.SkipValidation
+}
+
+// strictFields rejects what prepare would otherwise let through or fix up:
+// additional fields that are not strings, numbers or booleans, a level
+// outside 0 to 7 and a negative or non-numeric timestamp.
+func (g *Gelf) strictFields(gmap map[string]interface{}) error {
+	for k, v := range gmap {
+		if !isAdditionalField(k) {
+			continue
+		}
+		if v = g.coerce(v); !scalar(v) {
+			if _, ok := number(v); !ok {
+				return &ForbiddenFieldError{Field: k, Reason: "is not a string, number or boolean"}
+			}
+		}
+	}
+
+	if v, ok := gmap["level"]; ok {
+		level, ok := number(v)
+		if !ok || level < LevelEmergency || level > LevelDebug || level != math.Trunc(level) {
+			return &ForbiddenFieldError{Field: "level", Reason: "is not a level from 0 to 7"}
+		}
+	}
+	if v, ok := gmap["timestamp"]; ok {
+		if ts, ok := number(v); !ok || ts < 0 {
+			return &ForbiddenFieldError{Field: "timestamp", Reason: "is negative or not a number"}
+		}
+	}
+
+	return nil
+}
+
+// normalize fixes up what Strict rejects in the core fields: a level
+// outside 0 to 7 is clamped, a level name like "warning" replaced by its
+// level, a timestamp in a string parsed and a negative or non-numeric
+// timestamp dropped, so that the current time is sent. A level that is no number or known name is dropped too.
+func normalize(gmap map[string]interface{}) {
+	if v, ok := gmap["level"]; ok {
+		if level, ok := number(v); ok {
+			if level < LevelEmergency || level > LevelDebug || level != math.Trunc(level) {
+				gmap["level"] = int(math.Max(LevelEmergency, math.Min(LevelDebug, math.Trunc(level))))
+			}
+		} else if level, ok := levelName(v); ok {
+			gmap["level"] = level
+		} else {
+			delete(gmap, "level")
+		}
+	}
+	if v, ok := gmap["timestamp"]; ok {
+		if s, ok := v.(string); ok {
+			// a number in a string, as some shippers send it
+			if ts, err := strconv.ParseFloat(s, 64); err == nil {
+				v = ts
+				gmap["timestamp"] = ts
+			}
+		}
+		if ts, ok := number(v); !ok || ts < 0 {
+			delete(gmap, "timestamp")
+		}
+	}
+}
+
+func levelName(v interface{}) (int, bool) {
+	name, ok := v.(string)
+	if !ok {
+		return 0, false
+	}
+
+	return LevelFromString(name)
+}
+
+// number returns v as a float64 if it is a number of any kind.
+func number(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	switch n, _ := numeric(v); n := n.(type) {
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,117 @@
+package gelf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+)
+
+func strictGelf() *Gelf {
+	return New(Config{Connection: "discard", Compression: "none", Strict: true, SkipValidation: true})
+}
+
+func Test_Strict_itShouldRejectMissingShortMessages(t *testing.T) {
+	err := strictGelf().LogJSON(map[string]interface{}{"full_message": "Hello Graylog"})
+
+	assert.Equal(t, "Key short_message is required", err.Error())
+}
+
+func Test_Strict_itShouldRejectInvalidFieldNamesDespiteSkipValidation(t *testing.T) {
+	g := strictGelf()
+
+	err := g.LogWithFields("Hello Graylog", map[string]interface{}{"user name": "robert"})
+	assert.Equal(t, &ForbiddenFieldError{Field: "_user name", Reason: "contains characters not allowed in GELF field names"}, err)
+
+	err = g.LogJSON(map[string]interface{}{"short_message": "Hello Graylog", "_user name": "robert"})
+	assert.Equal(t, &ForbiddenFieldError{Field: "_user name", Reason: "contains characters not allowed in GELF field names"}, err)
+}
+
+func Test_Strict_itShouldRejectNonScalarFields(t *testing.T) {
+	err := strictGelf().LogWithFields("Hello Graylog", map[string]interface{}{
+		"user": map[string]interface{}{"name": "robert"},
+	})
+	assert.Equal(t, &ForbiddenFieldError{Field: "_user", Reason: "is not a string, number or boolean"}, err)
+
+	err = strictGelf().LogWithFields("Hello Graylog", map[string]interface{}{"tags": []string{"a", "b"}})
+	assert.Equal(t, &ForbiddenFieldError{Field: "_tags", Reason: "is not a string, number or boolean"}, err)
+}
+
+func Test_Strict_itShouldRejectLevelsOutOfRange(t *testing.T) {
+	g := strictGelf()
+	want := &ForbiddenFieldError{Field: "level", Reason: "is not a level from 0 to 7"}
+
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "level": 8}`))
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "level": -1}`))
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "level": 2.5}`))
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "level": "error"}`))
+	assert.Equal(t, nil, g.Log(`{"short_message": "Hello Graylog", "level": 7}`))
+}
+
+func Test_Strict_itShouldRejectNegativeTimestamps(t *testing.T) {
+	g := strictGelf()
+	want := &ForbiddenFieldError{Field: "timestamp", Reason: "is negative or not a number"}
+
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "timestamp": -1}`))
+	assert.Equal(t, want, g.Log(`{"short_message": "Hello Graylog", "timestamp": "yesterday"}`))
+	assert.Equal(t, nil, g.Log(`{"short_message": "Hello Graylog", "timestamp": 1385053862.3072}`))
+}
+
+func Test_Strict_itShouldAcceptCoercedFields(t *testing.T) {
+	err := strictGelf().LogWithFields("Hello Graylog", map[string]interface{}{
+		"ok":      true,
+		"status":  200,
+		"started": time.Now(),
+	})
+
+	assert.Equal(t, nil, err)
+}
+
+func Test_Strict_itShouldBeLenientWhenOff(t *testing.T) {
+	g := New(Config{Connection: "discard", Compression: "none"})
+
+	assert.Equal(t, nil, g.LogWithFields("Hello Graylog", map[string]interface{}{"tags": []string{"a", "b"}}))
+	assert.Equal(t, nil, g.Log(`{"short_message": "Hello Graylog", "level": 8, "timestamp": -1}`))
+}
+
+type strictCount uint16
+
+func Test_Strict_itShouldAcceptNumbersOfAnyKind(t *testing.T) {
+	for _, level := range []interface{}{
+		int(3), int8(3), int16(3), int32(3), int64(3),
+		uint(3), uint8(3), uint16(3), uint32(3), uint64(3),
+		float32(3), float64(3), json.Number("3"), strictCount(3),
+	} {
+		g := strictGelf()
+
+		err := g.LogJSON(map[string]interface{}{"short_message": "Hello Graylog", "level": level})
+		assert.Equal(t, nil, err)
+
+		err = g.LogWithFields("Hello Graylog", map[string]interface{}{"count": level})
+		assert.Equal(t, nil, err)
+	}
+}
+
+func Test_Strict_itShouldNormalizeTheCoreFieldsWhenOff(t *testing.T) {
+	g := New(Config{Compression: "none", Now: func() time.Time { return time.Unix(1356262644, 0) }})
+
+	for message, want := range map[string][]interface{}{
+		`{"short_message": "Hello Graylog", "level": 8}`:         {float64(LevelDebug), 1356262644.0},
+		`{"short_message": "Hello Graylog", "level": -1}`:        {float64(LevelEmergency), 1356262644.0},
+		`{"short_message": "Hello Graylog", "level": 2.5}`:       {float64(LevelCritical), 1356262644.0},
+		`{"short_message": "Hello Graylog", "level": "warning"}`: {float64(LevelWarning), 1356262644.0},
+		`{"short_message": "Hello Graylog", "level": "loud"}`:    {float64(defaultLevel), 1356262644.0},
+		`{"short_message": "Hello Graylog", "timestamp": -1}`:    {float64(defaultLevel), 1356262644.0},
+		`{"short_message": "Hello Graylog", "timestamp": "now"}`: {float64(defaultLevel), 1356262644.0},
+		`{"short_message": "Hello Graylog", "timestamp": 1.5}`:   {float64(defaultLevel), 1.5},
+		`{"short_message": "Hello Graylog", "timestamp": "1.5"}`: {float64(defaultLevel), 1.5},
+	} {
+		b, err := g.build(g.parse(message))
+		assert.Equal(t, nil, err)
+
+		res := g.ParseJson(string(b))
+		assert.Equal(t, want[0], res["level"])
+		assert.Equal(t, want[1], res["timestamp"])
+	}
+}
@@ -0,0 +1,15 @@
+package gelf
+
+// tee writes a copy of payload and a newline to Config.Tee. Errors of Tee
+// are ignored, it must not get in the way of sending.
+func (g *Gelf) tee(payload []byte) {
+	if g.Config.Tee == nil {
+		return
+	}
+
+	g.teeMu.Lock()
+	defer g.teeMu.Unlock()
+
+	g.Config.Tee.Write(payload)
+	g.Config.Tee.Write([]byte{'\n'})
+}
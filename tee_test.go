@@ -0,0 +1,67 @@
+package gelf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Tee_itShouldCopyTheSentPayload(t *testing.T) {
+	tee := new(bytes.Buffer)
+	g := New(Config{GraylogPort: 55644, Tee: tee})
+	defer g.Close()
+
+	received := UdpServer(55644)
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+
+	payload := <-received
+	assert.Equal(t, append(payload, '\n'), tee.Bytes())
+}
+
+func Test_Tee_itShouldCopyChunkedMessagesBeforeChunking(t *testing.T) {
+	conn := &fakeConn{}
+	tee := new(bytes.Buffer)
+	g := New(Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 100,
+		Tee:             tee,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	assert.Equal(t, nil, g.Log(strings.Repeat("Hello Graylog", 50)))
+	assert.T(t, len(conn.writes) > 1)
+
+	r := Reassembler{}
+	var payload []byte
+	for _, chunk := range conn.writes {
+		payload, _, _ = r.Add(chunk)
+	}
+	assert.Equal(t, append(payload, '\n'), tee.Bytes())
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func Test_Tee_itShouldNotAffectSending(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Tee:         failingWriter{},
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, 1, len(conn.writes))
+}
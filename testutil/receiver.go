@@ -0,0 +1,143 @@
+// Package testutil provides an in-memory GELF receiver for tests of code
+// logging to Graylog.
+package testutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/robertkowalski/graylog-golang"
+)
+
+// StartReceiver listens on a local UDP port and returns a Gelf sending to
+// it. Every message received is reassembled, decompressed and delivered
+// parsed on the channel. The listener and the Gelf are closed when the test
+// finishes, invalid chunks or messages received fail it then.
+func StartReceiver(t *testing.T) (*gelf.Gelf, <-chan map[string]interface{}) {
+	return StartReceiverConfig(t, gelf.Config{})
+}
+
+// StartReceiverConfig is StartReceiver with a Config, e.g. to test chunking
+// with a small MaxChunkSizeWan. GraylogHostname and GraylogPort are set to the
+// receiver.
+func StartReceiverConfig(t *testing.T, config gelf.Config) (*gelf.Gelf, <-chan map[string]interface{}) {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	config.GraylogHostname = addr.IP.String()
+	config.GraylogPort = addr.Port
+	g := gelf.New(config)
+
+	r := &receiver{
+		conn:     conn,
+		received: make(chan map[string]interface{}, 100),
+		errs:     make(chan error, 100),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go r.receive()
+
+	t.Cleanup(func() {
+		g.Close()
+		close(r.done)
+		conn.Close()
+		<-r.stopped
+
+		// reported here, t must not be used once the test returned
+		close(r.errs)
+		for err := range r.errs {
+			t.Error(err)
+		}
+	})
+
+	return g, r.received
+}
+
+type receiver struct {
+	conn     *net.UDPConn
+	received chan map[string]interface{}
+	errs     chan error
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// receive delivers messages until done is closed. Messages nobody reads
+// once the channel is full wait for the test to read them or to end.
+func (r *receiver) receive() {
+	defer close(r.stopped)
+
+	var chunks gelf.Reassembler
+	buffer := make([]byte, 65536)
+
+	for {
+		n, err := r.conn.Read(buffer)
+		if err != nil {
+			// closed by Cleanup
+			return
+		}
+
+		payload, done, err := chunks.Add(buffer[:n])
+		if err != nil {
+			r.fail(fmt.Errorf("Received an invalid GELF chunk: %s", err))
+			continue
+		}
+		if !done {
+			continue
+		}
+
+		message, err := decode(payload)
+		if err != nil {
+			r.fail(fmt.Errorf("Received an invalid GELF message: %s", err))
+			continue
+		}
+
+		select {
+		case r.received <- message:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// fail records err for Cleanup to report. Beyond 100 errors they are
+// dropped, the test has failed anyway.
+func (r *receiver) fail(err error) {
+	select {
+	case r.errs <- err:
+	default:
+	}
+}
+
+// decode decompresses payload by its magic bytes and unmarshals it.
+func decode(payload []byte) (map[string]interface{}, error) {
+	var r io.Reader = bytes.NewReader(payload)
+
+	var err error
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		r, err = gzip.NewReader(r)
+	case len(payload) > 0 && payload[0] == 0x78:
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var message map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
@@ -0,0 +1,87 @@
+package testutil
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/robertkowalski/graylog-golang"
+)
+
+func receiveOne(t *testing.T, received <-chan map[string]interface{}) map[string]interface{} {
+	select {
+	case message := <-received:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("message is not received")
+		return nil
+	}
+}
+
+func Test_StartReceiver_itShouldDeliverCompressedMessages(t *testing.T) {
+	g, received := StartReceiver(t)
+
+	g.LogWithFields("Hello Graylog", map[string]interface{}{"user": "robert"})
+
+	message := receiveOne(t, received)
+	assert.Equal(t, "Hello Graylog", message["short_message"])
+	assert.Equal(t, "robert", message["_user"])
+}
+
+func Test_StartReceiver_itShouldDeliverGzipMessages(t *testing.T) {
+	g, received := StartReceiverConfig(t, gelf.Config{Compression: "gzip"})
+
+	g.Info("Hello Graylog")
+
+	assert.Equal(t, "Hello Graylog", receiveOne(t, received)["short_message"])
+}
+
+func Test_StartReceiver_itShouldReassembleChunkedMessages(t *testing.T) {
+	g, received := StartReceiverConfig(t, gelf.Config{
+		Compression:     "none",
+		MaxChunkSizeWan: 100,
+	})
+
+	message := strings.Repeat("Hello Graylog", 100)
+	g.Log(message)
+	g.Log("Hello again")
+
+	assert.Equal(t, message, receiveOne(t, received)["short_message"])
+	assert.Equal(t, "Hello again", receiveOne(t, received)["short_message"])
+	assert.Equal(t, true, g.Stats().ChunksSent > 1)
+}
+
+func Test_StartReceiver_itShouldReassembleCompressedChunks(t *testing.T) {
+	g, received := StartReceiverConfig(t, gelf.Config{MaxChunkSizeWan: 10})
+
+	g.Log("Hello From Golang!")
+
+	assert.Equal(t, "Hello From Golang!", receiveOne(t, received)["short_message"])
+}
+
+func Test_StartReceiver_itShouldStopWhenMessagesAreNotRead(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	t.Run("unread", func(t *testing.T) {
+		g, received := StartReceiverConfig(t, gelf.Config{Compression: "none"})
+
+		deadline := time.Now().Add(5 * time.Second)
+		for len(received) < cap(received) && time.Now().Before(deadline) {
+			g.Info("Hello Graylog")
+			time.Sleep(time.Millisecond)
+		}
+		// the receiver blocks on this one
+		g.Info("Hello Graylog")
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, cap(received), len(received))
+	})
+
+	for i := 0; i < 100 && runtime.NumGoroutine() > before; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines before, %d after the test", before, after)
+	}
+}
@@ -0,0 +1,32 @@
+package gelf
+
+import "context"
+
+// Transport delivers messages in place of the built-in connections, e.g. by
+// publishing them to the exchange of a GELF AMQP input. WriteMessage gets
+// one compressed, unchunked GELF message and must not keep b after it
+// returns. Close is called by Close and Shutdown of the Gelf.
+type Transport interface {
+	WriteMessage(b []byte) error
+	Close() error
+}
+
+// transport compresses the message and hands it to Config.Transport.
+func (g *Gelf) transport(ctx context.Context, message []byte) error {
+	compressed := getBuffer()
+	defer putBuffer(compressed)
+	g.compress(compressed, message)
+	g.tee(compressed.Bytes())
+
+	return g.sendLocked(ctx, compressed.Bytes())
+}
+
+func (g *Gelf) writeMessage(b []byte) error {
+	if err := g.Config.Transport.WriteMessage(b); err != nil {
+		g.stats.sendErrors.Add(1)
+		return err
+	}
+
+	g.stats.bytesSent.Add(uint64(len(b)))
+	return nil
+}
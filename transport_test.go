@@ -0,0 +1,72 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+// fakeTransport records every message written to it.
+type fakeTransport struct {
+	messages [][]byte
+	err      error
+	closed   bool
+}
+
+func (t *fakeTransport) WriteMessage(b []byte) error {
+	if t.err != nil {
+		return t.err
+	}
+	t.messages = append(t.messages, append([]byte(nil), b...))
+	return nil
+}
+
+func (t *fakeTransport) Close() error {
+	t.closed = true
+	return nil
+}
+
+func Test_Transport_itShouldReceiveTheMessage(t *testing.T) {
+	transport := &fakeTransport{}
+	g := New(Config{
+		Compression: "none",
+		Transport:   transport,
+	})
+
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, 1, len(transport.messages))
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(transport.messages[0]))["short_message"])
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+
+	assert.Equal(t, nil, g.Close())
+	assert.Equal(t, true, transport.closed)
+}
+
+func Test_Transport_itShouldCompressButNotChunk(t *testing.T) {
+	transport := &fakeTransport{}
+	g := New(Config{
+		MaxChunkSizeWan: 10,
+		Transport:       transport,
+	})
+
+	g.Log(strings.Repeat("Hello Graylog", 100))
+	assert.Equal(t, 1, len(transport.messages))
+
+	r, err := zlib.NewReader(bytes.NewReader(transport.messages[0]))
+	assert.Equal(t, nil, err)
+	b, _ := io.ReadAll(r)
+	assert.Equal(t, strings.Repeat("Hello Graylog", 100), g.ParseJson(string(b))["short_message"])
+}
+
+func Test_Transport_itShouldReturnItsErrors(t *testing.T) {
+	fail := errors.New("channel closed")
+	g := New(Config{Transport: &fakeTransport{err: fail}})
+
+	assert.Equal(t, fail, g.Log("Hello Graylog"))
+	assert.Equal(t, uint64(1), g.Stats().SendErrors)
+}
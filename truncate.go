@@ -0,0 +1,49 @@
+package gelf
+
+import "unicode/utf8"
+
+const truncatedMarker = "…[truncated]"
+
+// truncate shortens short_message and full_message to MaxMessageBytes,
+// ending them with a marker, and sets _truncated if it cut anything.
+func (g *Gelf) truncate(gmap map[string]interface{}) {
+	truncated := false
+	for _, k := range []string{"short_message", "full_message"} {
+		s, ok := gmap[k].(string)
+		if !ok || len(s) <= g.Config.MaxMessageBytes {
+			continue
+		}
+
+		gmap[k] = truncateString(s, g.Config.MaxMessageBytes)
+		truncated = true
+	}
+
+	if truncated {
+		gmap["_truncated"] = true
+	}
+}
+
+// truncateString cuts s so that it ends with the marker and is at most max
+// bytes long, without splitting a UTF-8 sequence. If max is too small for
+// the marker, the marker itself is cut.
+func truncateString(s string, max int) string {
+	n := max - len(truncatedMarker)
+	if n <= 0 {
+		return cutString(truncatedMarker, max)
+	}
+
+	return cutString(s, n) + truncatedMarker
+}
+
+// cutString returns the longest prefix of s of at most n bytes ending on a
+// rune boundary.
+func cutString(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
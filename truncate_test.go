@@ -0,0 +1,71 @@
+package gelf
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_MaxMessageBytes_itShouldTruncateTheShortMessage(t *testing.T) {
+	g := New(Config{
+		GraylogPort:     55633,
+		Compression:     "none",
+		MaxMessageBytes: 64,
+	})
+
+	received := UdpServer(55633)
+	err := g.Log(strings.Repeat("a", 10000))
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(<-received))
+	short := res["short_message"].(string)
+	assert.Equal(t, 64, len(short))
+	assert.Equal(t, true, strings.HasSuffix(short, "…[truncated]"))
+	assert.Equal(t, true, res["_truncated"])
+}
+
+func Test_MaxMessageBytes_itShouldTruncateTheFullMessage(t *testing.T) {
+	g := New(Config{
+		Compression:     "none",
+		MaxMessageBytes: 64,
+	})
+
+	b, err := g.BuildMessageWithFields("Hello Graylog", map[string]interface{}{
+		"full_message": strings.Repeat("stack\n", 1000),
+	})
+	assert.Equal(t, nil, err)
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, 64, len(res["full_message"].(string)))
+	assert.Equal(t, true, res["_truncated"])
+}
+
+func Test_MaxMessageBytes_itShouldLeaveShortMessagesAlone(t *testing.T) {
+	g := New(Config{
+		Compression:     "none",
+		MaxMessageBytes: 64,
+	})
+
+	b, _ := g.BuildMessage("Hello Graylog")
+
+	res := g.ParseJson(string(b))
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, nil, res["_truncated"])
+}
+
+func Test_truncateString_itShouldNotSplitRunes(t *testing.T) {
+	s := truncateString(strings.Repeat("ü", 100), 21)
+
+	assert.Equal(t, true, utf8.ValidString(s))
+	assert.Equal(t, true, len(s) <= 21)
+	assert.Equal(t, "üüü…[truncated]", s)
+}
+
+func Test_truncateString_itShouldCutTheMarkerBelowItsLength(t *testing.T) {
+	assert.Equal(t, "…[tru", truncateString("Hello Graylog", 7))
+	assert.Equal(t, "", truncateString("Hello Graylog", 2))
+	assert.Equal(t, truncatedMarker, truncateString(strings.Repeat("a", 100), len(truncatedMarker)))
+}
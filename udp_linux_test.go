@@ -0,0 +1,33 @@
+package gelf
+
+import (
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_Log_itShouldSetTheUDPSendBuffer(t *testing.T) {
+	g := New(Config{
+		GraylogPort:        55622,
+		Compression:        "none",
+		UDPSendBufferBytes: 100000,
+	})
+	defer g.Close()
+
+	received := UdpServer(55622)
+	assert.Equal(t, nil, g.Log("Hello Graylog"))
+	assert.Equal(t, "Hello Graylog", g.ParseJson(string(<-received))["short_message"])
+
+	raw, err := g.conn.(*net.UDPConn).SyscallConn()
+	assert.Equal(t, nil, err)
+
+	var size int
+	raw.Control(func(fd uintptr) {
+		size, err = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	})
+	assert.Equal(t, nil, err)
+	// Linux doubles the size to make room for its bookkeeping
+	assert.Equal(t, 2*100000, size)
+}
@@ -0,0 +1,61 @@
+package gelf
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// NewFromURL returns a Gelf configured from a connection string:
+//
+//	gelf+udp://graylog.example.com:12201
+//	gelf+tcp://graylog.example.com:12201
+//	gelf+tls://graylog.example.com:12201
+//	https://graylog.example.com/gelf
+//
+// The port defaults to 12201 for gelf+ schemes and to 80 or 443 for HTTP.
+func NewFromURL(raw string) (*Gelf, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("URL %s has no host", raw)
+	}
+
+	config := Config{
+		GraylogHostname: u.Hostname(),
+	}
+
+	port := defaultGraylogPort
+	switch u.Scheme {
+	case "gelf+udp":
+	case "gelf+tcp":
+		config.Connection = "tcp"
+	case "gelf+tls":
+		config.Connection = "tcp"
+		config.UseTLS = true
+	case "http", "https":
+		if u.Path != "" && u.Path != "/gelf" {
+			return nil, fmt.Errorf("URL %s must have the path /gelf", raw)
+		}
+		config.Connection = "http"
+		config.UseTLS = u.Scheme == "https"
+		port = 80
+		if config.UseTLS {
+			port = 443
+		}
+	default:
+		return nil, fmt.Errorf("URL scheme %s is not supported", u.Scheme)
+	}
+
+	if u.Port() != "" {
+		port, err = strconv.Atoi(u.Port())
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.GraylogPort = port
+
+	return New(config), nil
+}
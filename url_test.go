@@ -0,0 +1,52 @@
+package gelf
+
+import (
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_NewFromURL_itShouldConfigureTheTransport(t *testing.T) {
+	tests := []struct {
+		url        string
+		hostname   string
+		port       int
+		connection string
+		useTLS     bool
+	}{
+		{"gelf+udp://graylog.example.com:12202", "graylog.example.com", 12202, "wan", false},
+		{"gelf+udp://graylog.example.com", "graylog.example.com", 12201, "wan", false},
+		{"gelf+tcp://10.0.0.5:12201", "10.0.0.5", 12201, "tcp", false},
+		{"gelf+tls://graylog.example.com:12203", "graylog.example.com", 12203, "tcp", true},
+		{"http://graylog.example.com/gelf", "graylog.example.com", 80, "http", false},
+		{"https://graylog.example.com:8443/gelf", "graylog.example.com", 8443, "http", true},
+		{"https://[::1]", "::1", 443, "http", true},
+	}
+
+	for _, test := range tests {
+		g, err := NewFromURL(test.url)
+		assert.Equal(t, nil, err)
+
+		assert.Equal(t, test.hostname, g.Config.GraylogHostname)
+		assert.Equal(t, test.port, g.Config.GraylogPort)
+		assert.Equal(t, test.connection, g.Config.Connection)
+		assert.Equal(t, test.useTLS, g.Config.UseTLS)
+	}
+}
+
+func Test_NewFromURL_itShouldRejectInvalidURLs(t *testing.T) {
+	urls := []string{
+		"gelf+sctp://graylog.example.com",
+		"graylog.example.com:12201",
+		"gelf+udp://",
+		"gelf+udp://graylog.example.com:port",
+		"https://graylog.example.com/api",
+		"://graylog",
+	}
+
+	for _, url := range urls {
+		g, err := NewFromURL(url)
+		assert.NotEqual(t, nil, err)
+		assert.Equal(t, (*Gelf)(nil), g)
+	}
+}
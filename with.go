@@ -0,0 +1,50 @@
+package gelf
+
+// With returns a logger adding fields to every message, on top of the
+// StaticFields of g. It shares the connection, queue and stats of g, so
+// creating one is cheap. Calling With on the result merges the fields again.
+func (g *Gelf) With(fields map[string]interface{}) *Gelf {
+	config := g.Config
+	config.StaticFields = make(map[string]interface{}, len(g.Config.StaticFields)+len(fields))
+	for k, v := range g.Config.StaticFields {
+		config.StaticFields[k] = v
+	}
+	for k, v := range fields {
+		config.StaticFields[g.FieldName(k)] = v
+	}
+
+	return &Gelf{
+		Config: config,
+		root:   g.base(),
+	}
+}
+
+// Named returns a logger sending name as _logger, joined to the LoggerName
+// of g with a dot like zap does, e.g. "api.billing". It shares the
+// connection of g like With.
+func (g *Gelf) Named(name string) *Gelf {
+	if name == "" {
+		return g
+	}
+
+	config := g.Config
+	if config.LoggerName == "" {
+		config.LoggerName = name
+	} else {
+		config.LoggerName += "." + name
+	}
+
+	return &Gelf{
+		Config: config,
+		root:   g.base(),
+	}
+}
+
+// base returns the Gelf owning the connection.
+func (g *Gelf) base() *Gelf {
+	if g.root != nil {
+		return g.root
+	}
+
+	return g
+}
@@ -0,0 +1,143 @@
+package gelf
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/bmizerany/assert"
+)
+
+func Test_With_itShouldAddTheFields(t *testing.T) {
+	g := New(Config{
+		GraylogPort:  55612,
+		Compression:  "none",
+		StaticFields: map[string]interface{}{"service": "billing"},
+	})
+
+	received := UdpServer(55612)
+	g.With(map[string]interface{}{"request_id": "abc"}).Info("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "abc", res["_request_id"])
+	assert.Equal(t, "billing", res["_service"])
+}
+
+func Test_With_itShouldMergeTheFieldsOfTheParent(t *testing.T) {
+	g := New(Config{
+		GraylogPort: 55613,
+		Compression: "none",
+	})
+
+	child := g.With(map[string]interface{}{"request_id": "abc", "user": "robert"})
+	grandchild := child.With(map[string]interface{}{"user": "kowalski"})
+
+	received := UdpServer(55613)
+	grandchild.Log("Hello Graylog")
+
+	res := g.ParseJson(string(<-received))
+	assert.Equal(t, "abc", res["_request_id"])
+	assert.Equal(t, "kowalski", res["_user"])
+	assert.Equal(t, nil, g.Config.StaticFields["_user"])
+	assert.Equal(t, "robert", child.Config.StaticFields["_user"])
+}
+
+func Test_With_itShouldShareTheConnection(t *testing.T) {
+	dials := 0
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			return conn, nil
+		},
+	})
+
+	child := g.With(map[string]interface{}{"request_id": "abc"})
+	g.Log("parent")
+	child.Log("child")
+
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, 2, len(conn.writes))
+	assert.Equal(t, nil, g.ParseJson(string(conn.writes[0]))["_request_id"])
+	assert.Equal(t, "abc", g.ParseJson(string(conn.writes[1]))["_request_id"])
+	assert.Equal(t, uint64(2), child.Stats().MessagesSent)
+}
+
+func Test_With_itShouldNotCloseTheParent(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.Log("parent")
+	assert.Equal(t, nil, g.With(nil).Close())
+	assert.Equal(t, net.Conn(conn), g.conn)
+}
+
+func Test_Named_itShouldComposeTheLoggerName(t *testing.T) {
+	g := New(Config{Compression: "none", LoggerName: "api"})
+	named := g.Named("billing").Named("invoices")
+
+	assert.Equal(t, "api.billing.invoices", named.Config.LoggerName)
+	assert.Equal(t, "api", g.Config.LoggerName)
+	assert.Equal(t, g, g.Named(""))
+}
+
+func Test_Named_itShouldAddTheLoggerField(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression: "none",
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.Log("parent")
+	g.Named("billing").Log("child")
+
+	assert.Equal(t, 2, len(conn.writes))
+	assert.Equal(t, nil, g.ParseJson(string(conn.writes[0]))["_logger"])
+	assert.Equal(t, "billing", g.ParseJson(string(conn.writes[1]))["_logger"])
+}
+
+func Test_IncludeSequence_itShouldNumberTheMessages(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		IncludeSequence: true,
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.Info("first")
+	g.With(map[string]interface{}{"request_id": "abc"}).Info("second")
+	g.Debug("third")
+
+	assert.Equal(t, 3, len(conn.writes))
+	for i, b := range conn.writes {
+		assert.Equal(t, float64(i+1), g.ParseJson(string(b))["_sequence_number"])
+	}
+}
+
+func Test_IncludeSequence_itShouldNotNumberFilteredMessages(t *testing.T) {
+	conn := &fakeConn{}
+	g := New(Config{
+		Compression:     "none",
+		IncludeSequence: true,
+		MinLevel:        intPtr(LevelInfo),
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+
+	g.Info("first")
+	g.Debug("filtered")
+	g.Info("second")
+
+	assert.Equal(t, float64(2), g.ParseJson(string(conn.writes[1]))["_sequence_number"])
+}
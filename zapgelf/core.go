@@ -0,0 +1,101 @@
+// Package zapgelf sends zap log entries to Graylog through a zapcore.Core,
+// which can be teed with the cores a zap logger already writes to.
+package zapgelf
+
+import (
+	"time"
+
+	"github.com/robertkowalski/graylog-golang"
+	"go.uber.org/zap/zapcore"
+)
+
+type core struct {
+	zapcore.LevelEnabler
+
+	gelf   *gelf.Gelf
+	fields map[string]interface{}
+}
+
+// NewZapCore returns a core sending the entries enab enables through g.
+func NewZapCore(g *gelf.Gelf, enab zapcore.LevelEnabler) zapcore.Core {
+	return &core{
+		LevelEnabler: enab,
+		gelf:         g,
+	}
+}
+
+func (c *core) With(fields []zapcore.Field) zapcore.Core {
+	return &core{
+		LevelEnabler: c.LevelEnabler,
+		gelf:         c.gelf,
+		fields:       encode(c.fields, fields),
+	}
+}
+
+func (c *core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.gelf.LogJSON(gelfMessage(c.gelf, entry, encode(c.fields, fields)))
+}
+
+// Sync waits for queued messages to be sent.
+func (c *core) Sync() error {
+	return c.gelf.Flush()
+}
+
+// encode returns the fields of base and fields in one map, without
+// modifying base.
+func encode(base map[string]interface{}, fields []zapcore.Field) map[string]interface{} {
+	enc := zapcore.NewMapObjectEncoder()
+	for k, v := range base {
+		enc.Fields[k] = v
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	return enc.Fields
+}
+
+func gelfMessage(g *gelf.Gelf, entry zapcore.Entry, fields map[string]interface{}) map[string]interface{} {
+	gmap := map[string]interface{}{
+		"short_message": entry.Message,
+		"level":         level(entry.Level),
+		"timestamp":     float64(entry.Time.UnixNano()) / float64(time.Second),
+	}
+	if entry.Stack != "" {
+		gmap["full_message"] = entry.Stack
+	}
+	if entry.LoggerName != "" {
+		gmap["_logger"] = entry.LoggerName
+	}
+
+	for k, v := range fields {
+		gmap[g.FieldName(k)] = v
+	}
+
+	return gmap
+}
+
+func level(l zapcore.Level) int {
+	switch l {
+	case zapcore.PanicLevel:
+		return gelf.LevelAlert
+	case zapcore.FatalLevel, zapcore.DPanicLevel:
+		return gelf.LevelCritical
+	case zapcore.ErrorLevel:
+		return gelf.LevelError
+	case zapcore.WarnLevel:
+		return gelf.LevelWarning
+	case zapcore.InfoLevel:
+		return gelf.LevelInfo
+	default:
+		return gelf.LevelDebug
+	}
+}
@@ -0,0 +1,100 @@
+package zapgelf
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/bmizerany/assert"
+	"github.com/robertkowalski/graylog-golang"
+	"github.com/robertkowalski/graylog-golang/testutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func Test_NewZapCore_itShouldSendEntries(t *testing.T) {
+	g, received := testutil.StartReceiver(t)
+	logger := zap.New(NewZapCore(g, zapcore.InfoLevel)).With(zap.String("service", "billing"))
+
+	logger.Warn("Hello Graylog", zap.Int("attempt", 3), zap.Error(errors.New("boom")))
+	logger.Debug("not enabled")
+
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+	res := <-received
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, float64(gelf.LevelWarning), res["level"])
+	assert.Equal(t, "billing", res["_service"])
+	assert.Equal(t, float64(3), res["_attempt"])
+	assert.Equal(t, "boom", res["_error"])
+}
+
+func Test_NewZapCore_itShouldFlushOnSync(t *testing.T) {
+	g, received := testutil.StartReceiverConfig(t, gelf.Config{Async: true})
+	logger := zap.New(NewZapCore(g, zapcore.DebugLevel))
+
+	logger.Info("Hello Graylog")
+	assert.Equal(t, nil, logger.Sync())
+
+	assert.Equal(t, uint64(1), g.Stats().MessagesSent)
+	assert.Equal(t, "Hello Graylog", (<-received)["short_message"])
+}
+
+func Test_With_itShouldNotChangeTheParent(t *testing.T) {
+	parent := NewZapCore(gelf.New(gelf.Config{}), zapcore.InfoLevel).With([]zapcore.Field{zap.String("a", "1")})
+	parent.With([]zapcore.Field{zap.String("b", "2")})
+
+	assert.Equal(t, map[string]interface{}{"a": "1"}, parent.(*core).fields)
+}
+
+func Test_gelfMessage_itShouldMapTheEntryToGelf(t *testing.T) {
+	entry := zapcore.Entry{
+		Message:    "Hello Graylog",
+		Level:      zapcore.ErrorLevel,
+		Time:       time.Unix(1356262644, 500000000),
+		LoggerName: "billing",
+		Stack:      "main.main()",
+	}
+
+	res := gelfMessage(gelf.New(gelf.Config{}), entry, map[string]interface{}{"user": "robert"})
+
+	assert.Equal(t, "Hello Graylog", res["short_message"])
+	assert.Equal(t, gelf.LevelError, res["level"])
+	assert.Equal(t, 1356262644.5, res["timestamp"])
+	assert.Equal(t, "main.main()", res["full_message"])
+	assert.Equal(t, "billing", res["_logger"])
+	assert.Equal(t, "robert", res["_user"])
+}
+
+func Test_gelfMessage_itShouldUseTheFieldPrefix(t *testing.T) {
+	prefix := "_app_"
+	g := gelf.New(gelf.Config{FieldPrefix: &prefix})
+
+	res := gelfMessage(g, zapcore.Entry{}, map[string]interface{}{"user": "robert", "_service": "billing"})
+
+	assert.Equal(t, "robert", res["_app_user"])
+	assert.Equal(t, "billing", res["_app_service"])
+}
+
+func Test_level_itShouldMapZapLevels(t *testing.T) {
+	assert.Equal(t, gelf.LevelCritical, level(zapcore.FatalLevel))
+	assert.Equal(t, gelf.LevelAlert, level(zapcore.PanicLevel))
+	assert.Equal(t, gelf.LevelCritical, level(zapcore.DPanicLevel))
+	assert.Equal(t, gelf.LevelError, level(zapcore.ErrorLevel))
+	assert.Equal(t, gelf.LevelWarning, level(zapcore.WarnLevel))
+	assert.Equal(t, gelf.LevelInfo, level(zapcore.InfoLevel))
+	assert.Equal(t, gelf.LevelDebug, level(zapcore.DebugLevel))
+}
+
+func Test_NewZapCore_itShouldAttachTheCallSite(t *testing.T) {
+	g, received := testutil.StartReceiverConfig(t, gelf.Config{IncludeCaller: true})
+	logger := zap.New(NewZapCore(g, zapcore.InfoLevel))
+
+	_, _, line, _ := runtime.Caller(0)
+	logger.Info("Hello Graylog")
+
+	res := <-received
+	assert.Equal(t, "core_test.go", filepath.Base(res["_file"].(string)))
+	assert.Equal(t, float64(line+1), res["_line"])
+}